@@ -0,0 +1,244 @@
+package supernova
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects how access log entries are written
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON writes one JSON object per request
+	AccessLogJSON AccessLogFormat = iota
+
+	// AccessLogLogfmt writes key=value pairs per request
+	AccessLogLogfmt
+
+	// AccessLogApache writes the Apache combined log format, ignoring Fields
+	AccessLogApache
+)
+
+// AccessLogField is an optional field written to the access log
+type AccessLogField int
+
+// Optional access log fields
+const (
+	FieldRequestID AccessLogField = iota
+	FieldUserAgent
+	FieldBytes
+	FieldRoute
+	FieldLatency
+)
+
+// AccessLogConfig holds the options for the access log middleware
+type AccessLogConfig struct {
+	Format AccessLogFormat
+
+	// Writer receives the entries, defaults to os.Stdout
+	Writer io.Writer
+
+	// Handler writes the entries instead of Format and Writer when set
+	Handler slog.Handler
+
+	// Fields are logged alongside time, method, path, status and remote IP.
+	// All fields are logged when empty.
+	Fields []AccessLogField
+
+	// Skip drops entries for requests it returns true for
+	Skip func(*Request) bool
+
+	// SkipPaths drops entries for these paths such as health checks
+	SkipPaths []string
+
+	// SampleRate is the fraction of successful requests logged. Requests
+	// answered with a 4xx or 5xx status are always logged. Zero logs everything.
+	SampleRate float64
+}
+
+// AccessLog returns middleware that logs every request once it's been handled
+func AccessLog(config AccessLogConfig) func(*Request, func()) {
+	handler := config.Handler
+	if handler == nil {
+		handler = newAccessLogHandler(config.Format, config.Writer)
+	}
+	logger := slog.New(handler)
+
+	fields := config.Fields
+	if len(fields) == 0 || config.Format == AccessLogApache {
+		fields = []AccessLogField{FieldRequestID, FieldUserAgent, FieldBytes, FieldRoute, FieldLatency}
+	}
+
+	skipPaths := make(map[string]bool, len(config.SkipPaths))
+	for _, p := range config.SkipPaths {
+		skipPaths[p] = true
+	}
+
+	return func(req *Request, next func()) {
+		start := time.Now()
+		next()
+
+		if skipPaths[req.BaseUrl] || (config.Skip != nil && config.Skip(req)) {
+			return
+		}
+
+		status := req.responseStatus()
+		if config.SampleRate > 0 && config.SampleRate < 1 && status < 400 && rand.Float64() >= config.SampleRate {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", req.GetMethod()),
+			slog.String("path", string(req.RequestURI())),
+			slog.String("proto", string(req.Request.Header.Protocol())),
+			slog.Int("status", status),
+			slog.String("remote_ip", req.RemoteIP().String()),
+		}
+
+		if p := req.Principal(); p != nil {
+			attrs = append(attrs, slog.String("user", p.Name))
+		}
+
+		for _, f := range fields {
+			switch f {
+			case FieldRequestID:
+				if id := req.RequestID(); id != "" {
+					attrs = append(attrs, slog.String("request_id", id))
+				}
+			case FieldUserAgent:
+				attrs = append(attrs, slog.String("user_agent", string(req.UserAgent())))
+			case FieldBytes:
+				attrs = append(attrs, slog.Int("bytes", req.responseSize()))
+			case FieldRoute:
+				attrs = append(attrs, slog.String("route", req.RoutePattern()))
+			case FieldLatency:
+				attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+			}
+		}
+
+		if config.Format == AccessLogApache && config.Handler == nil {
+			attrs = append(attrs, slog.String("referer", string(req.Referer())))
+		}
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(context.Background(), level, "request", attrs...)
+	}
+}
+
+// newAccessLogHandler returns the slog handler writing format to w
+func newAccessLogHandler(format AccessLogFormat, w io.Writer) slog.Handler {
+	if w == nil {
+		w = os.Stdout
+	}
+
+	switch format {
+	case AccessLogLogfmt:
+		return slog.NewTextHandler(w, nil)
+	case AccessLogApache:
+		return &apacheHandler{w: w}
+	default:
+		return slog.NewJSONHandler(w, nil)
+	}
+}
+
+// apacheHandler writes records in the Apache combined log format
+type apacheHandler struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// Enabled reports that every level is written
+func (h *apacheHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle writes the record as a single line
+func (h *apacheHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]string, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.String()
+		return true
+	})
+
+	value := func(key string) string {
+		if v := attrs[key]; v != "" {
+			return v
+		}
+		return "-"
+	}
+
+	bytes := value("bytes")
+	if bytes == "0" {
+		bytes = "-"
+	}
+
+	var b strings.Builder
+	b.WriteString(value("remote_ip"))
+	b.WriteString(" - ")
+	b.WriteString(value("user"))
+	b.WriteString(" [")
+	b.WriteString(r.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	b.WriteString("] \"")
+	b.WriteString(attrs["method"] + " " + attrs["path"] + " " + attrs["proto"])
+	b.WriteString("\" ")
+	b.WriteString(value("status"))
+	b.WriteString(" ")
+	b.WriteString(bytes)
+	b.WriteString(" " + strconv.Quote(value("referer")))
+	b.WriteString(" " + strconv.Quote(value("user_agent")))
+	b.WriteString("\n")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs returns the handler unchanged as the format has fixed fields
+func (h *apacheHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup returns the handler unchanged as the format has fixed fields
+func (h *apacheHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// responseStatus returns the status code sent to the client
+func (r *Request) responseStatus() int {
+	if r.timeoutResponse != nil {
+		return r.timeoutResponse.StatusCode()
+	}
+
+	return r.Response.StatusCode()
+}
+
+// responseSize returns the size of the body sent to the client. Streamed
+// bodies report their Content-Length or 0 as reading them would consume the stream.
+func (r *Request) responseSize() int {
+	if r.timeoutResponse != nil {
+		return len(r.timeoutResponse.Body())
+	}
+
+	if r.Response.IsBodyStream() {
+		if n := r.Response.Header.ContentLength(); n > 0 {
+			return n
+		}
+		return 0
+	}
+
+	return len(r.Response.Body())
+}
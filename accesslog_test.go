@@ -0,0 +1,127 @@
+package supernova
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog_JSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := New()
+	s.Use(AccessLog(AccessLogConfig{Writer: &buf, SkipPaths: []string{"/health"}}))
+	s.Use(RequestID(RequestIDConfig{}))
+	s.Get("/users/:id", func(r *Request) {
+		r.Send("hello")
+	})
+	s.Get("/health", func(r *Request) {
+		r.Send("ok")
+	})
+
+	_, err := doRequest(s, "GET /users/5 HTTP/1.1\r\nHost: localhost\r\nUser-Agent: test\r\nX-Request-ID: abc\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = doRequest(s, "GET /health HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 entry got %d: %s", len(lines), buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"method":     "GET",
+		"path":       "/users/5",
+		"route":      "/users/:id",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_agent": "test",
+		"request_id": "abc",
+	}
+
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("Expected %s %v got %v", k, v, entry[k])
+		}
+	}
+
+	if _, ok := entry["latency"]; !ok {
+		t.Error("Expected latency to be logged")
+	}
+}
+
+func TestAccessLog_Fields(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := New()
+	s.Use(AccessLog(AccessLogConfig{
+		Format: AccessLogLogfmt,
+		Writer: &buf,
+		Fields: []AccessLogField{FieldRoute},
+	}))
+	s.Get("/test", func(r *Request) {})
+
+	err := sendRequest(s, "GET", "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := buf.String()
+	if !strings.Contains(line, "route=/test") || !strings.Contains(line, "status=200") {
+		t.Errorf("Expected route and status got %s", line)
+	}
+
+	if strings.Contains(line, "latency=") {
+		t.Errorf("Expected only configured fields got %s", line)
+	}
+}
+
+func TestAccessLog_Apache(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := New()
+	s.Use(AccessLog(AccessLogConfig{Format: AccessLogApache, Writer: &buf}))
+
+	_, err := doRequest(s, "GET /missing?a=1 HTTP/1.1\r\nHost: localhost\r\nUser-Agent: test\r\nReferer: http://example.com\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := buf.String()
+	prefix := "0.0.0.0 - - ["
+	suffix := "] \"GET /missing?a=1 HTTP/1.1\" 404 13 \"http://example.com\" \"test\"\n"
+	if !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, suffix) {
+		t.Errorf("Expected combined log line got %q", line)
+	}
+}
+
+func TestAccessLog_Timeout(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := New()
+	s.Use(AccessLog(AccessLogConfig{Writer: &buf}))
+	s.Get("/slow", func(r *Request) {
+		<-r.Ctx.Done()
+	}).Timeout(10 * time.Millisecond)
+
+	err := sendRequest(s, "GET", "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"status":503`) {
+		t.Errorf("Expected timeout status to be logged got %s", buf.String())
+	}
+}
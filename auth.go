@@ -0,0 +1,167 @@
+package supernova
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Principal is the identity established by an authentication middleware
+type Principal struct {
+	// Name is the username, key owner or token subject
+	Name string
+
+	// Scheme is the authentication scheme used e.g. "basic", "bearer", "jwt"
+	Scheme string
+
+	// Claims holds any extra attributes of the identity such as JWT claims
+	Claims map[string]interface{}
+}
+
+// TokenValidator checks a bearer token or API key and returns the identity it belongs to
+type TokenValidator func(token string) (*Principal, error)
+
+// ErrInvalidToken is returned by validators when the token is not accepted
+var ErrInvalidToken = errors.New("invalid token")
+
+// BasicAuth returns middleware requiring HTTP Basic credentials checked by validate
+func BasicAuth(realm string, validate func(user, password string) bool) func(*Request, func()) {
+	challenge := `Basic realm="` + strings.Replace(realm, `"`, `\"`, -1) + `"`
+
+	return func(req *Request, next func()) {
+		user, password, ok := parseBasicAuth(req.Request.Header.Peek("Authorization"))
+		if !ok || !validate(user, password) {
+			req.Response.Header.Set("WWW-Authenticate", challenge)
+			req.Error(401, "Unauthorized")
+			return
+		}
+
+		req.SetPrincipal(&Principal{Name: user, Scheme: "basic"})
+		next()
+	}
+}
+
+// BasicAuthUsers returns middleware requiring HTTP Basic credentials matching
+// one of the user/password pairs. Credentials are compared in constant time.
+func BasicAuthUsers(realm string, users map[string]string) func(*Request, func()) {
+	hashed := make(map[[32]byte][32]byte, len(users))
+	for user, password := range users {
+		hashed[sha256.Sum256([]byte(user))] = sha256.Sum256([]byte(password))
+	}
+
+	return BasicAuth(realm, func(user, password string) bool {
+		userHash := sha256.Sum256([]byte(user))
+		passHash := sha256.Sum256([]byte(password))
+
+		match := 0
+		for u, p := range hashed {
+			userMatch := subtle.ConstantTimeCompare(u[:], userHash[:])
+			passMatch := subtle.ConstantTimeCompare(p[:], passHash[:])
+			match |= userMatch & passMatch
+		}
+
+		return match == 1
+	})
+}
+
+// BearerAuth returns middleware requiring an "Authorization: Bearer" token accepted by validate
+func BearerAuth(validate TokenValidator) func(*Request, func()) {
+	return func(req *Request, next func()) {
+		token, ok := parseBearer(req.Request.Header.Peek("Authorization"))
+		if !ok {
+			req.Response.Header.Set("WWW-Authenticate", "Bearer")
+			req.Error(401, "Unauthorized")
+			return
+		}
+
+		authenticate(req, next, token, validate)
+	}
+}
+
+// APIKeyAuth returns middleware requiring an API key in the given header accepted by validate
+func APIKeyAuth(header string, validate TokenValidator) func(*Request, func()) {
+	return func(req *Request, next func()) {
+		key := string(req.Request.Header.Peek(header))
+		if key == "" {
+			req.Error(401, "Unauthorized")
+			return
+		}
+
+		authenticate(req, next, key, validate)
+	}
+}
+
+// StaticTokens returns a TokenValidator accepting the given token to name pairs.
+// Tokens are compared in constant time.
+func StaticTokens(tokens map[string]string) TokenValidator {
+	hashed := make(map[[32]byte]string, len(tokens))
+	for token, name := range tokens {
+		hashed[sha256.Sum256([]byte(token))] = name
+	}
+
+	return func(token string) (*Principal, error) {
+		tokenHash := sha256.Sum256([]byte(token))
+
+		var name string
+		found := 0
+		for t, n := range hashed {
+			if subtle.ConstantTimeCompare(t[:], tokenHash[:]) == 1 {
+				name = n
+				found = 1
+			}
+		}
+
+		if found == 0 {
+			return nil, ErrInvalidToken
+		}
+
+		return &Principal{Name: name, Scheme: "bearer"}, nil
+	}
+}
+
+// authenticate runs validate against token and stores the principal or rejects the request
+func authenticate(req *Request, next func(), token string, validate TokenValidator) {
+	principal, err := validate(token)
+	if err != nil || principal == nil {
+		req.Response.Header.Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		req.Error(401, "Unauthorized")
+		return
+	}
+
+	req.SetPrincipal(principal)
+	next()
+}
+
+// parseBasicAuth decodes the user and password from a Basic Authorization header
+func parseBasicAuth(header []byte) (string, string, bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !bytes.EqualFold(header[:len(prefix)], []byte(prefix)) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(header[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+
+	creds := string(decoded)
+	i := strings.IndexByte(creds, ':')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return creds[:i], creds[i+1:], true
+}
+
+// parseBearer returns the token from a Bearer Authorization header
+func parseBearer(header []byte) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !bytes.EqualFold(header[:len(prefix)], []byte(prefix)) {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(header[len(prefix):])), true
+}
@@ -0,0 +1,262 @@
+package supernova
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBasicAuthUsers(t *testing.T) {
+	var name string
+	s := New()
+	s.Use(BasicAuthUsers("admin", map[string]string{"gopher": "secret"}))
+	s.Get("/test", func(r *Request) {
+		name = r.Principal().Name
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 401 {
+		t.Errorf("Expected 401 got %d", resp.StatusCode())
+	}
+
+	if h := string(resp.Header.Peek("WWW-Authenticate")); h != `Basic realm="admin"` {
+		t.Errorf("Unexpected challenge %q", h)
+	}
+
+	creds := base64.StdEncoding.EncodeToString([]byte("gopher:secret"))
+	resp, err = doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nAuthorization: Basic "+creds+"\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 || name != "gopher" {
+		t.Errorf("Expected gopher to be authenticated got %d %q", resp.StatusCode(), name)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	var name string
+	s := New()
+	s.Use(BearerAuth(StaticTokens(map[string]string{"abc123": "service"})))
+	s.Get("/test", func(r *Request) {
+		name = r.Principal().Name
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nAuthorization: Bearer wrong\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 401 {
+		t.Errorf("Expected 401 got %d", resp.StatusCode())
+	}
+
+	resp, err = doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nAuthorization: Bearer abc123\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 || name != "service" {
+		t.Errorf("Expected service to be authenticated got %d %q", resp.StatusCode(), name)
+	}
+}
+
+func TestVerifyJWT_HS256(t *testing.T) {
+	secret := []byte("shh")
+	config := JWTConfig{Secret: secret, Audience: "api"}
+
+	cases := []struct {
+		Claims map[string]interface{}
+		Err    error
+	}{
+		{map[string]interface{}{"sub": "gopher", "aud": "api", "exp": time.Now().Add(time.Hour).Unix()}, nil},
+		{map[string]interface{}{"sub": "gopher", "aud": []string{"web", "api"}}, nil},
+		{map[string]interface{}{"sub": "gopher", "aud": "api", "exp": time.Now().Add(-time.Hour).Unix()}, ErrTokenExpired},
+		{map[string]interface{}{"sub": "gopher", "aud": "api", "nbf": time.Now().Add(time.Hour).Unix()}, ErrTokenNotValidYet},
+		{map[string]interface{}{"sub": "gopher", "aud": "web"}, ErrInvalidAudience},
+	}
+
+	for i, val := range cases {
+		token := signJWT(t, "HS256", "", secret, val.Claims)
+		p, err := VerifyJWT(token, config)
+		if err != val.Err {
+			t.Errorf("case %d: Expected %v got %v", i, val.Err, err)
+		}
+
+		if err == nil && p.Name != "gopher" {
+			t.Errorf("case %d: Expected subject gopher got %q", i, p.Name)
+		}
+	}
+
+	// signed with a different secret
+	token := signJWT(t, "HS256", "", []byte("other"), map[string]interface{}{"sub": "gopher", "aud": "api"})
+	if _, err := VerifyJWT(token, config); err != ErrInvalidSignature {
+		t.Errorf("Expected invalid signature got %v", err)
+	}
+}
+
+func TestVerifyJWT_EmptySecret(t *testing.T) {
+	claims := map[string]interface{}{"sub": "gopher"}
+
+	// an unset secret must not accept tokens signed with an empty key
+	token := signJWT(t, "HS256", "", []byte{}, claims)
+	if _, err := VerifyJWT(token, JWTConfig{Secret: []byte("")}); err == nil {
+		t.Error("Expected token signed with an empty secret to fail")
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"empty","k":""}]}`)); err == nil {
+		t.Error("Expected empty symmetric key to fail")
+	}
+}
+
+func TestVerifyJWT_PublicKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{"sub": "gopher"}
+
+	if _, err := VerifyJWT(signJWT(t, "RS256", "", rsaKey, claims), JWTConfig{PublicKey: &rsaKey.PublicKey}); err != nil {
+		t.Errorf("RS256: %v", err)
+	}
+
+	if _, err := VerifyJWT(signJWT(t, "ES256", "", ecKey, claims), JWTConfig{PublicKey: &ecKey.PublicKey}); err != nil {
+		t.Errorf("ES256: %v", err)
+	}
+
+	// an HMAC token must not be verified using the RSA public key as the secret
+	if _, err := VerifyJWT(signJWT(t, "HS256", "", []byte("x"), claims), JWTConfig{PublicKey: &rsaKey.PublicKey}); err != ErrUnsupportedAlg {
+		t.Errorf("Expected unsupported alg got %v", err)
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	set := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa1","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"hmac1","k":%q}
+	]}`,
+		enc(rsaKey.N.Bytes()), enc([]byte{1, 0, 1}),
+		enc(ecKey.X.FillBytes(make([]byte, 32))), enc(ecKey.Y.FillBytes(make([]byte, 32))),
+		enc([]byte("shh")))
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(set), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := JWTConfig{KeySet: jwks}
+	claims := map[string]interface{}{"sub": "gopher"}
+
+	tokens := []string{
+		signJWT(t, "RS256", "rsa1", rsaKey, claims),
+		signJWT(t, "ES256", "ec1", ecKey, claims),
+		signJWT(t, "HS256", "hmac1", []byte("shh"), claims),
+	}
+
+	for _, token := range tokens {
+		if _, err := VerifyJWT(token, config); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// token signed by the RSA key but claiming the EC key id
+	if _, err := VerifyJWT(signJWT(t, "RS256", "ec1", rsaKey, claims), config); err == nil {
+		t.Error("Expected key mismatch to fail")
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	var p *Principal
+	s := New()
+	s.Use(JWTAuth(JWTConfig{Secret: []byte("shh")}))
+	s.Get("/test", func(r *Request) {
+		p = r.Principal()
+	})
+
+	token := signJWT(t, "HS256", "", []byte("shh"), map[string]interface{}{"sub": "gopher", "role": "admin"})
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nAuthorization: Bearer "+token+"\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 || p == nil {
+		t.Fatalf("Expected authenticated request got %d", resp.StatusCode())
+	}
+
+	if p.Name != "gopher" || p.Claims["role"] != "admin" {
+		t.Errorf("Unexpected principal %+v", p)
+	}
+}
+
+// signJWT builds a token for the given algorithm and key
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
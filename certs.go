@@ -0,0 +1,228 @@
+package supernova
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// certWatchInterval is how often ServeTLS checks certificate files for changes
+var certWatchInterval = 10 * time.Second
+
+// CertManager holds certificate pairs loaded from files, selects them by the
+// SNI hostname of the client and reloads them when the files change
+type CertManager struct {
+	mutex sync.RWMutex
+	pairs []*certPair
+
+	watching int32
+
+	// logger reports reload failures when set
+	logger Logger
+}
+
+// certPair is a certificate loaded from a cert and key file
+type certPair struct {
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+// NewCertManager returns an empty CertManager
+func NewCertManager() *CertManager {
+	return new(CertManager)
+}
+
+// Add loads a certificate pair. The first pair added is served to clients
+// that don't send a matching SNI hostname.
+func (m *CertManager) Add(certFile, keyFile string) error {
+	pair := &certPair{certFile: certFile, keyFile: keyFile}
+	err := pair.load()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.pairs = append(m.pairs, pair)
+	m.mutex.Unlock()
+	return nil
+}
+
+// Reload loads every pair from disk again. Pairs that fail to load keep
+// serving the previous certificate and the first error is returned.
+func (m *CertManager) Reload() error {
+	return m.reload(true)
+}
+
+// reload loads pairs from disk, only those whose files changed unless force is set
+func (m *CertManager) reload(force bool) error {
+	m.mutex.RLock()
+	pairs := make([]*certPair, len(m.pairs))
+	copy(pairs, m.pairs)
+	m.mutex.RUnlock()
+
+	var firstErr error
+	for _, p := range pairs {
+		if !force {
+			m.mutex.RLock()
+			loaded := p.modTime
+			m.mutex.RUnlock()
+
+			if !p.changedSince(loaded) {
+				continue
+			}
+		}
+
+		next := &certPair{certFile: p.certFile, keyFile: p.keyFile}
+		err := next.load()
+		if err != nil {
+			if m.logger != nil {
+				m.logger.Error("Error reloading certificate", "file", p.certFile, "err", err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		m.mutex.Lock()
+		p.cert, p.modTime = next.cert, next.modTime
+		m.mutex.Unlock()
+	}
+
+	return firstErr
+}
+
+// GetCertificate returns the certificate matching the SNI hostname or the
+// first certificate. It can be used as tls.Config.GetCertificate.
+func (m *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if len(m.pairs) == 0 {
+		return nil, nil
+	}
+
+	if cert := m.matchLocked(hello); cert != nil {
+		return cert, nil
+	}
+
+	return m.pairs[0].cert, nil
+}
+
+// match returns the certificate matching the SNI hostname or nil
+func (m *CertManager) match(hello *tls.ClientHelloInfo) *tls.Certificate {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.matchLocked(hello)
+}
+
+// matchLocked is match with the mutex already held
+func (m *CertManager) matchLocked(hello *tls.ClientHelloInfo) *tls.Certificate {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if name == "" {
+		return nil
+	}
+
+	for _, p := range m.pairs {
+		if p.cert.Leaf != nil && p.cert.Leaf.VerifyHostname(name) == nil {
+			return p.cert
+		}
+	}
+
+	return nil
+}
+
+// Watch reloads changed certificates every interval and all certificates on
+// SIGHUP until ctx is done. Only the first call starts watching.
+func (m *CertManager) Watch(ctx context.Context, interval time.Duration) {
+	if !atomic.CompareAndSwapInt32(&m.watching, 0, 1) {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer signal.Stop(hup)
+		defer atomic.StoreInt32(&m.watching, 0)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.reload(false)
+			case <-hup:
+				m.reload(true)
+			}
+		}
+	}()
+}
+
+// len returns the number of pairs
+func (m *CertManager) len() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.pairs)
+}
+
+// load reads the pair from disk
+func (p *certPair) load() error {
+	modTime, err := p.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	p.cert = &cert
+	p.modTime = modTime
+	return nil
+}
+
+// changedSince reports if either file was modified after being loaded at loaded
+func (p *certPair) changedSince(loaded time.Time) bool {
+	modTime, err := p.filesModTime()
+	return err == nil && !modTime.Equal(loaded)
+}
+
+// filesModTime returns the latest modification time of the cert and key file
+func (p *certPair) filesModTime() (time.Time, error) {
+	certInfo, err := os.Stat(p.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(p.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+
+	return certInfo.ModTime(), nil
+}
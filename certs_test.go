@@ -0,0 +1,99 @@
+package supernova
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCertManager_SNI(t *testing.T) {
+	dir := t.TempDir()
+	aFile, aKey := writeTestCert(t, dir, "a", newTestCert(t, "a", nil, []string{"a.test"}))
+	bFile, bKey := writeTestCert(t, dir, "b", newTestCert(t, "b", nil, []string{"*.b.test"}))
+
+	m := NewCertManager()
+	if err := m.Add(aFile, aKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(bFile, bKey); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ServerName string
+		CN         string
+	}{
+		{"a.test", "a"},
+		{"api.b.test", "b"},
+		{"unknown.test", "a"},
+		{"", "a"},
+	}
+
+	for _, val := range cases {
+		cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: val.ServerName})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cert.Leaf.Subject.CommonName != val.CN {
+			t.Errorf("%q Expected %s got %s", val.ServerName, val.CN, cert.Leaf.Subject.CommonName)
+		}
+	}
+}
+
+func TestCertManager_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server", newTestCert(t, "old", nil, []string{"localhost"}))
+
+	m := NewCertManager()
+	if err := m.Add(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.Watch(ctx, 10*time.Millisecond)
+
+	writeTestCert(t, dir, "server", newTestCert(t, "new", nil, []string{"localhost"}))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	if !waitForCN(m, "new") {
+		t.Fatal("Expected changed certificate to be reloaded")
+	}
+}
+
+func TestCertManager_ReloadKeepsOldOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server", newTestCert(t, "old", nil, nil))
+
+	m := NewCertManager()
+	if err := m.Add(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(certFile, []byte("garbage"), 0600)
+	if err := m.Reload(); err == nil {
+		t.Error("Expected reload of invalid certificate to fail")
+	}
+
+	if !waitForCN(m, "old") {
+		t.Error("Expected previous certificate to still be served")
+	}
+}
+
+// waitForCN waits for the manager to serve a certificate with the common name
+func waitForCN(m *CertManager, cn string) bool {
+	for i := 0; i < 100; i++ {
+		cert, _ := m.GetCertificate(&tls.ClientHelloInfo{})
+		if cert != nil && cert.Leaf.Subject.CommonName == cn {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
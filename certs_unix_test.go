@@ -0,0 +1,33 @@
+//go:build !windows
+
+package supernova
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCertManager_WatchSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server", newTestCert(t, "old", nil, []string{"localhost"}))
+
+	m := NewCertManager()
+	if err := m.Add(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.Watch(ctx, time.Hour)
+
+	// the interval is too long to pick up the change so only SIGHUP reloads
+	writeTestCert(t, dir, "server", newTestCert(t, "hup", nil, []string{"localhost"}))
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	if !waitForCN(m, "hup") {
+		t.Fatal("Expected SIGHUP to reload certificate")
+	}
+}
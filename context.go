@@ -0,0 +1,80 @@
+package supernova
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// disconnectPollInterval is how often a watched connection is checked for a closed peer
+var disconnectPollInterval = 100 * time.Millisecond
+
+// requestContext is the context carried on a Request. It's cancelled when the
+// server context ends, the handler returns or the client goes away.
+type requestContext struct {
+	context.Context
+	cancel context.CancelFunc
+
+	conn  net.Conn
+	watch sync.Once
+}
+
+// newRequestContext derives a request context from the server context
+func newRequestContext(parent context.Context, conn net.Conn) *requestContext {
+	ctx, cancel := context.WithCancel(parent)
+	return &requestContext{
+		Context: ctx,
+		cancel:  cancel,
+		conn:    conn,
+	}
+}
+
+// Done starts watching the client connection the first time it's called so
+// requests that never wait on the context don't pay for the watcher
+func (c *requestContext) Done() <-chan struct{} {
+	if c.conn != nil {
+		c.watch.Do(func() {
+			go c.watchConn()
+		})
+	}
+
+	return c.Context.Done()
+}
+
+// watchConn cancels the context once the client closes the connection
+func (c *requestContext) watchConn() {
+	ticker := time.NewTicker(disconnectPollInterval)
+	defer ticker.Stop()
+
+	done := c.Context.Done()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			closed, readable := peekConn(c.conn)
+			if closed {
+				c.cancel()
+				return
+			}
+
+			// the client sent more data so it's still there but the
+			// connection can't be checked without consuming it
+			if readable {
+				return
+			}
+		}
+	}
+}
+
+// baseConn unwraps TLS and graceful connections down to the underlying conn
+func baseConn(c net.Conn) net.Conn {
+	for {
+		u, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return c
+		}
+		c = u.NetConn()
+	}
+}
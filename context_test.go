@@ -0,0 +1,53 @@
+package supernova
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRequest_CtxClientDisconnect(t *testing.T) {
+	cancelled := make(chan error, 1)
+
+	s := New()
+	s.Get("/wait", func(r *Request) {
+		select {
+		case <-r.Ctx.Done():
+			cancelled <- r.Ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Write([]byte("GET /wait HTTP/1.1\r\nHost: test\r\n\r\n"))
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+
+	if err := <-cancelled; err == nil {
+		t.Error("Expected request context to be cancelled on disconnect")
+	}
+}
+
+func TestRequest_CtxServerClose(t *testing.T) {
+	s := New()
+	reqCtx := newRequestContext(s.ctx, nil)
+
+	s.cancel()
+	select {
+	case <-reqCtx.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected request context to be cancelled with the server")
+	}
+}
@@ -0,0 +1,208 @@
+package supernova
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig holds the options for cross-origin resource sharing
+type CORSConfig struct {
+	// AllowedOrigins is a list of origins allowed to make requests.
+	// An entry may be "*" to allow any origin or contain a single
+	// wildcard such as "https://*.example.com".
+	AllowedOrigins []string
+
+	// AllowedOriginPatterns are matched against the origin when no entry
+	// in AllowedOrigins matches.
+	AllowedOriginPatterns []*regexp.Regexp
+
+	// AllowedHeaders are the headers a client may send. When empty the
+	// headers requested by the preflight are echoed back.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers the browser may read
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies and authorization headers to be sent
+	AllowCredentials bool
+
+	// MaxAge is how long the preflight response may be cached
+	MaxAge time.Duration
+}
+
+// EnableCORS adds middleware that sets the CORS headers on responses and
+// answers preflight requests with the methods registered for the path.
+// It should be enabled before any authentication middleware so preflight
+// requests are answered without credentials.
+func (sn *Server) EnableCORS(config CORSConfig) {
+	sn.Use(sn.corsMiddleware(config))
+}
+
+// corsMiddleware builds the middleware function used by EnableCORS
+func (sn *Server) corsMiddleware(config CORSConfig) func(*Request, func()) {
+	allowAll := false
+	var exact []string
+	var wildcards [][2]string
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			allowAll = true
+		case strings.Contains(origin, "*"):
+			i := strings.Index(origin, "*")
+			wildcards = append(wildcards, [2]string{origin[:i], origin[i+1:]})
+		default:
+			exact = append(exact, origin)
+		}
+	}
+
+	originAllowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+
+		lower := strings.ToLower(origin)
+		for _, o := range exact {
+			if o == lower {
+				return true
+			}
+		}
+
+		for _, w := range wildcards {
+			// the wildcard must match at least one character
+			if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+				return true
+			}
+		}
+
+		for _, re := range config.AllowedOriginPatterns {
+			if re.MatchString(origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(req *Request, next func()) {
+		// responses differ with the origin so caches must key on it even
+		// for requests without one
+		header := &req.Response.Header
+		header.Add("Vary", "Origin")
+
+		origin := string(req.Request.Header.Peek("Origin"))
+		if origin == "" {
+			next()
+			return
+		}
+
+		if !originAllowed(origin) {
+			next()
+			return
+		}
+
+		if allowAll && !config.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		reqMethod := string(req.Request.Header.Peek("Access-Control-Request-Method"))
+		if req.GetMethod() != "OPTIONS" || reqMethod == "" {
+			if exposedHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+			next()
+			return
+		}
+
+		// preflight request
+		methods := sn.allowedMethods(req.BaseUrl, reqMethod)
+		if len(methods) == 0 {
+			next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		// the route exists but not for the requested method
+		if !containsString(methods, reqMethod) {
+			header.Del("Access-Control-Allow-Origin")
+			header.Del("Access-Control-Allow-Credentials")
+			req.SetStatusCode(403)
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if allowedHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowedHeaders)
+		} else if reqHeaders := req.Request.Header.Peek("Access-Control-Request-Headers"); len(reqHeaders) > 0 {
+			header.SetBytesV("Access-Control-Allow-Headers", reqHeaders)
+		}
+
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+
+		req.SetStatusCode(204)
+	}
+}
+
+// allowedMethods returns the methods that have a route registered for path.
+// Routes registered for all methods allow the requested method.
+func (sn *Server) allowedMethods(path, requested string) []string {
+	parts := splitPath(path)
+
+	var methods []string
+	for method, node := range sn.paths {
+		if node.find(parts) == nil {
+			continue
+		}
+
+		if method == "" {
+			method = requested
+		}
+
+		methods = appendUnique(methods, method)
+	}
+
+	if len(methods) > 0 {
+		methods = appendUnique(methods, "OPTIONS")
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// containsString reports if val is in list
+func containsString(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendUnique appends val to list if it isn't already present
+func appendUnique(list []string, val string) []string {
+	if containsString(list, val) {
+		return list
+	}
+
+	return append(list, val)
+}
@@ -0,0 +1,122 @@
+package supernova
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestServer_EnableCORS_Preflight(t *testing.T) {
+	s := New()
+	s.EnableCORS(CORSConfig{
+		AllowedOrigins: []string{"https://*.example.com"},
+		MaxAge:         time.Minute,
+	})
+
+	s.Get("/users/:id", func(*Request) {})
+	s.Delete("/users/:id", func(*Request) {})
+	s.Post("/other", func(*Request) {})
+
+	resp, err := doRequest(s, "OPTIONS /users/5 HTTP/1.1\r\nHost: test\r\nOrigin: https://app.example.com\r\n"+
+		"Access-Control-Request-Method: DELETE\r\nAccess-Control-Request-Headers: X-Token\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 204 {
+		t.Errorf("Expected 204 got %d", resp.StatusCode())
+	}
+
+	if m := string(resp.Header.Peek("Access-Control-Allow-Methods")); m != "DELETE, GET, OPTIONS" {
+		t.Errorf("Unexpected allowed methods %q", m)
+	}
+
+	if o := string(resp.Header.Peek("Access-Control-Allow-Origin")); o != "https://app.example.com" {
+		t.Errorf("Unexpected allowed origin %q", o)
+	}
+
+	if h := string(resp.Header.Peek("Access-Control-Allow-Headers")); h != "X-Token" {
+		t.Errorf("Unexpected allowed headers %q", h)
+	}
+
+	if a := string(resp.Header.Peek("Access-Control-Max-Age")); a != "60" {
+		t.Errorf("Unexpected max age %q", a)
+	}
+
+	// a method without a route for the path is rejected
+	resp, err = doRequest(s, "OPTIONS /users/5 HTTP/1.1\r\nHost: test\r\nOrigin: https://app.example.com\r\n"+
+		"Access-Control-Request-Method: PUT\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 403 {
+		t.Errorf("Expected 403 got %d", resp.StatusCode())
+	}
+
+	if o := resp.Header.Peek("Access-Control-Allow-Origin"); len(o) != 0 {
+		t.Errorf("Expected no allowed origin got %q", o)
+	}
+
+	// the wildcard needs at least one character
+	resp, err = doRequest(s, "OPTIONS /users/5 HTTP/1.1\r\nHost: test\r\nOrigin: https://.example.com\r\n"+
+		"Access-Control-Request-Method: GET\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := resp.Header.Peek("Access-Control-Allow-Origin"); len(o) != 0 {
+		t.Errorf("Expected empty label origin to be rejected got %q", o)
+	}
+
+	// responses without an origin still vary by it
+	resp, err = doRequest(s, "GET /users/5 HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := string(resp.Header.Peek("Vary")); v != "Origin" {
+		t.Errorf("Expected Vary Origin got %q", v)
+	}
+}
+
+func TestServer_EnableCORS_Origins(t *testing.T) {
+	cases := []struct {
+		Origin  string
+		Allowed bool
+	}{
+		{"https://example.com", true},
+		{"https://api.internal.dev", true},
+		{"https://evil.com", false},
+	}
+
+	s := New()
+	s.EnableCORS(CORSConfig{
+		AllowedOrigins:        []string{"https://example.com"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^https://[a-z]+\.internal\.dev$`)},
+		ExposedHeaders:        []string{"X-Total"},
+		AllowCredentials:      true,
+	})
+
+	s.Get("/test", func(r *Request) {
+		r.Error(400, "Bad Request")
+	})
+
+	for _, val := range cases {
+		resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nOrigin: "+val.Origin+"\r\n\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		origin := string(resp.Header.Peek("Access-Control-Allow-Origin"))
+		if val.Allowed && origin != val.Origin {
+			t.Errorf("%s Expected origin to be allowed got %q", val.Origin, origin)
+		} else if !val.Allowed && origin != "" {
+			t.Errorf("%s Expected origin to be rejected got %q", val.Origin, origin)
+		}
+
+		if val.Allowed && string(resp.Header.Peek("Access-Control-Expose-Headers")) != "X-Total" {
+			t.Errorf("%s Expected exposed headers", val.Origin)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package supernova
+
+import (
+	"encoding/json"
+	"expvar"
+	"html"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rtpprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// debugRoute is a route in the table dumped by the debug endpoints
+type debugRoute struct {
+	Method  string `json:"method"`
+	Route   string `json:"route"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// MountDebug adds pprof profiles, a goroutine dump, runtime stats and the
+// route table under prefix, defaulting to /debug. Every endpoint runs guard
+// first when it's set, for example BasicAuth, so they aren't exposed publicly.
+//
+// The endpoints are prefix/pprof, prefix/pprof/:profile, prefix/goroutines,
+// prefix/vars and prefix/routes.
+func (sn *Server) MountDebug(prefix string, guard func(*Request, func())) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/debug"
+	}
+
+	guarded := func(handler func(*Request)) func(*Request) {
+		if guard == nil {
+			return handler
+		}
+
+		return func(req *Request) {
+			guard(req, func() {
+				handler(req)
+			})
+		}
+	}
+
+	adapt := func(h func(w http.ResponseWriter, r *http.Request)) func(*Request) {
+		handler := fasthttpadaptor.NewFastHTTPHandlerFunc(h)
+		return func(req *Request) {
+			handler(req.RequestCtx)
+		}
+	}
+
+	sn.Get(prefix+"/pprof", guarded(pprofIndex(prefix+"/pprof/")))
+	sn.Get(prefix+"/pprof/cmdline", guarded(adapt(pprof.Cmdline)))
+	sn.Get(prefix+"/pprof/profile", guarded(adapt(pprof.Profile)))
+	sn.Get(prefix+"/pprof/trace", guarded(adapt(pprof.Trace)))
+	sn.All(prefix+"/pprof/symbol", guarded(adapt(pprof.Symbol)))
+	sn.Get(prefix+"/pprof/:profile", guarded(func(req *Request) {
+		name := req.RouteParam("profile")
+		if rtpprof.Lookup(name) == nil {
+			req.Error(fasthttp.StatusNotFound, "Unknown profile")
+			return
+		}
+
+		adapt(pprof.Handler(name).ServeHTTP)(req)
+	}))
+
+	sn.Get(prefix+"/goroutines", guarded(func(req *Request) {
+		req.Response.Header.SetContentType("text/plain; charset=utf-8")
+		rtpprof.Lookup("goroutine").WriteTo(req, 2)
+	}))
+
+	sn.Get(prefix+"/vars", guarded(func(req *Request) {
+		vars := map[string]interface{}{
+			"goroutines": runtime.NumGoroutine(),
+			"num_cpu":    runtime.NumCPU(),
+			"go_version": runtime.Version(),
+		}
+
+		expvar.Do(func(kv expvar.KeyValue) {
+			vars[kv.Key] = json.RawMessage(kv.Value.String())
+		})
+
+		req.JSON(fasthttp.StatusOK, vars)
+	}))
+
+	sn.Get(prefix+"/routes", guarded(func(req *Request) {
+		req.JSON(fasthttp.StatusOK, sn.routeTable())
+	}))
+}
+
+// pprofIndex lists the available profiles with links below base
+func pprofIndex(base string) func(*Request) {
+	return func(req *Request) {
+		profiles := rtpprof.Profiles()
+		sort.Slice(profiles, func(i, j int) bool {
+			return profiles[i].Name() < profiles[j].Name()
+		})
+
+		var b strings.Builder
+		b.WriteString("<html><head><title>profiles</title></head><body><table>\n")
+		for _, p := range profiles {
+			name := html.EscapeString(p.Name())
+			b.WriteString("<tr><td>" + strconv.Itoa(p.Count()) + "</td><td><a href=\"" + base + name + "?debug=1\">" + name + "</a></td></tr>\n")
+		}
+		for _, name := range []string{"cmdline", "profile", "symbol", "trace"} {
+			b.WriteString("<tr><td></td><td><a href=\"" + base + name + "\">" + name + "</a></td></tr>\n")
+		}
+		b.WriteString("</table></body></html>\n")
+
+		req.Response.Header.SetContentType("text/html; charset=utf-8")
+		req.Write([]byte(b.String()))
+	}
+}
+
+// routeTable returns every registered route sorted by pattern and method
+func (sn *Server) routeTable() []debugRoute {
+	var routes []debugRoute
+	for method, node := range sn.paths {
+		if method == "" {
+			method = "ALL"
+		}
+
+		seen := make(map[*Route]bool)
+		node.walk(func(r *Route) {
+			if seen[r] {
+				return
+			}
+			seen[r] = true
+
+			dr := debugRoute{Method: method, Route: r.route}
+			if r.timeout > 0 {
+				dr.Timeout = r.timeout.String()
+			}
+			routes = append(routes, dr)
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Route != routes[j].Route {
+			return routes[i].Route < routes[j].Route
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// walk calls f for the route of every edge node below n
+func (n *Node) walk(f func(*Route)) {
+	for _, child := range n.children {
+		if child.isEdge && child.route != nil {
+			f(child.route)
+		}
+		child.walk(f)
+	}
+}
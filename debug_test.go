@@ -0,0 +1,72 @@
+package supernova
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_MountDebug(t *testing.T) {
+	s := New()
+	s.Get("/users/:id", func(r *Request) {}).Timeout(time.Second)
+	s.MountDebug("/internal/", BasicAuthUsers("debug", map[string]string{"admin": "secret"}))
+
+	resp, err := doRequest(s, "GET /internal/routes HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 401 {
+		t.Errorf("Expected guard to reject request got %d", resp.StatusCode())
+	}
+
+	auth := "Authorization: Basic YWRtaW46c2VjcmV0\r\n"
+	resp, err = doRequest(s, "GET /internal/routes HTTP/1.1\r\nHost: localhost\r\n"+auth+"\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []debugRoute
+	if err := json.Unmarshal(resp.Body(), &routes); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, r := range routes {
+		if r.Method == "GET" && r.Route == "/users/:id" && r.Timeout == "1s" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Expected /users/:id in route table got %v", routes)
+	}
+
+	cases := map[string]string{
+		"/internal/pprof":                   "goroutine?debug=1",
+		"/internal/pprof/goroutine?debug=1": "goroutine profile",
+		"/internal/goroutines":              "goroutine",
+		"/internal/vars":                    "memstats",
+	}
+
+	for path, expected := range cases {
+		resp, err := doRequest(s, "GET "+path+" HTTP/1.1\r\nHost: localhost\r\n"+auth+"\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode() != 200 || !strings.Contains(string(resp.Body()), expected) {
+			t.Errorf("%s: Expected 200 containing %s got %d", path, expected, resp.StatusCode())
+		}
+	}
+
+	resp, err = doRequest(s, "GET /internal/pprof/missing HTTP/1.1\r\nHost: localhost\r\n"+auth+"\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 404 {
+		t.Errorf("Expected unknown profile to 404 got %d", resp.StatusCode())
+	}
+}
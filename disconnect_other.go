@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package supernova
+
+import "net"
+
+// peekConn isn't supported on this platform so disconnects are never detected
+func peekConn(c net.Conn) (closed, readable bool) {
+	return false, false
+}
@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package supernova
+
+import (
+	"net"
+	"syscall"
+)
+
+// peekConn checks the connection without consuming data and reports whether
+// the peer has closed it or has sent more data
+func peekConn(c net.Conn) (closed, readable bool) {
+	sc, ok := baseConn(c).(syscall.Conn)
+	if !ok {
+		return false, false
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+
+	var buf [1]byte
+	err = raw.Control(func(fd uintptr) {
+		n, _, rerr := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case rerr == syscall.EAGAIN || rerr == syscall.EWOULDBLOCK:
+		case rerr != nil:
+			closed = true
+		case n == 0:
+			closed = true
+		default:
+			readable = true
+		}
+	})
+	if err != nil {
+		return true, false
+	}
+
+	return closed, readable
+}
@@ -0,0 +1,199 @@
+package supernova
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultCheckTimeout limits checks that don't set a timeout
+const defaultCheckTimeout = 5 * time.Second
+
+// errShuttingDown is reported by readiness once shutdown has started
+var errShuttingDown = errors.New("shutting down")
+
+// HealthCheck is a named check run by the health endpoints
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+
+	// Timeout limits how long the check may run, defaults to 5 seconds
+	Timeout time.Duration
+
+	// CacheFor reuses the last result for this long, zero runs the check on every probe
+	CacheFor time.Duration
+
+	// Liveness adds the check to /healthz as well as /readyz. Only checks
+	// that restarting the process would fix belong there.
+	Liveness bool
+}
+
+// Health serves liveness on /healthz and readiness on /readyz
+type Health struct {
+	server *Server
+
+	mutex  sync.Mutex
+	checks []*healthCheck
+
+	// showErrors reports check errors in responses instead of only failed
+	showErrors bool
+}
+
+// healthCheck holds a check and its cached result
+type healthCheck struct {
+	HealthCheck
+
+	mutex   sync.Mutex
+	err     error
+	checked time.Time
+}
+
+// healthResponse is the body written by the health endpoints
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Health adds the /healthz and /readyz routes on the first call and returns
+// the Health used to register checks. Readiness fails once shutdown starts.
+func (sn *Server) Health() *Health {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	if sn.health == nil {
+		sn.health = &Health{server: sn}
+		sn.Get("/healthz", sn.health.liveness)
+		sn.Get("/readyz", sn.health.readiness)
+	}
+
+	return sn.health
+}
+
+// AddCheck registers a check run by the health endpoints
+func (h *Health) AddCheck(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultCheckTimeout
+	}
+
+	h.mutex.Lock()
+	h.checks = append(h.checks, &healthCheck{HealthCheck: check})
+	h.mutex.Unlock()
+}
+
+// ShowErrors reports the errors of failing checks in responses. Errors can
+// hold hostnames or credentials so by default failing checks only report
+// "failed" and the errors are logged.
+func (h *Health) ShowErrors(show bool) {
+	h.mutex.Lock()
+	h.showErrors = show
+	h.mutex.Unlock()
+}
+
+// liveness responds with the result of the liveness checks
+func (h *Health) liveness(req *Request) {
+	h.respond(req, true, nil)
+}
+
+// readiness responds with the result of every check
+func (h *Health) readiness(req *Request) {
+	var err error
+	if h.server.isShuttingDown() {
+		err = errShuttingDown
+	}
+
+	h.respond(req, false, err)
+}
+
+// respond runs the checks concurrently and writes a 200 if all pass or a 503
+func (h *Health) respond(req *Request, liveness bool, serverErr error) {
+	h.mutex.Lock()
+	var checks []*healthCheck
+	for _, c := range h.checks {
+		if !liveness || c.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	showErrors := h.showErrors
+	h.mutex.Unlock()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			// checks outlive the probe so a client disconnecting doesn't
+			// cancel a check whose result is cached for later probes
+			errs[i] = c.run(h.server.ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	resp := healthResponse{Status: "ok"}
+	if len(checks) > 0 || serverErr != nil {
+		resp.Checks = make(map[string]string, len(checks)+1)
+	}
+
+	if serverErr != nil {
+		resp.Status = "unavailable"
+		resp.Checks["server"] = serverErr.Error()
+	}
+
+	for i, c := range checks {
+		if errs[i] != nil {
+			resp.Status = "unavailable"
+			h.server.logger.Warn("Health check failed", "check", c.Name, "err", errs[i])
+			resp.Checks[c.Name] = "failed"
+			if showErrors {
+				resp.Checks[c.Name] = errs[i].Error()
+			}
+			continue
+		}
+		resp.Checks[c.Name] = "ok"
+	}
+
+	code := fasthttp.StatusOK
+	if resp.Status != "ok" {
+		code = fasthttp.StatusServiceUnavailable
+	}
+
+	req.Response.Header.Set("Cache-Control", "no-store")
+	req.JSON(code, resp)
+}
+
+// run returns the cached result or runs the check with its timeout
+func (c *healthCheck) run(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.CacheFor > 0 && !c.checked.IsZero() && time.Since(c.checked) < c.CacheFor {
+		return c.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// a cancelled check says nothing about what it checks so isn't cached
+	if ctx.Err() == context.Canceled {
+		return err
+	}
+	c.err = err
+	c.checked = time.Now()
+
+	return c.err
+}
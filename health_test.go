@@ -0,0 +1,167 @@
+package supernova
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_Health(t *testing.T) {
+	var runs int32
+
+	s := New()
+	s.logger = discardLogger{}
+	health := s.Health()
+	if s.Health() != health {
+		t.Error("Expected Health to return the same instance")
+	}
+
+	health.AddCheck(HealthCheck{
+		Name:     "cached",
+		CacheFor: time.Minute,
+		Liveness: true,
+		Check: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+	})
+
+	resp, err := doRequest(s, "GET /healthz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 {
+		t.Errorf("Expected 200 got %d", resp.StatusCode())
+	}
+
+	health.AddCheck(HealthCheck{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("unreachable")
+		},
+	})
+
+	resp, err = doRequest(s, "GET /readyz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 503 || !strings.Contains(string(resp.Body()), `"slow":"failed"`) {
+		t.Errorf("Expected slow check to fail got %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	// errors are only shown once enabled
+	health.ShowErrors(true)
+	resp, err = doRequest(s, "GET /readyz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(resp.Body()), `"slow":"context deadline exceeded"`) {
+		t.Errorf("Expected slow check to time out got %s", resp.Body())
+	}
+
+	// liveness ignores readiness only checks
+	resp, err = doRequest(s, "GET /healthz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 {
+		t.Errorf("Expected 200 got %d", resp.StatusCode())
+	}
+
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("Expected cached check to run once got %d", n)
+	}
+}
+
+func TestServer_Health_Shutdown(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.Health()
+
+	resp, err := doRequest(s, "GET /readyz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 {
+		t.Errorf("Expected 200 got %d", resp.StatusCode())
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = doRequest(s, "GET /readyz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 503 || !strings.Contains(string(resp.Body()), "shutting down") {
+		t.Errorf("Expected readiness to fail after shutdown got %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp, err = doRequest(s, "GET /healthz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 {
+		t.Errorf("Expected liveness to pass after shutdown got %d", resp.StatusCode())
+	}
+}
+
+func TestServer_Health_Cancelled(t *testing.T) {
+	s := New()
+	s.Health().AddCheck(HealthCheck{
+		Name: "db",
+		Check: func(ctx context.Context) error {
+			return ctx.Err()
+		},
+		CacheFor: time.Minute,
+	})
+
+	// a probe that has gone away doesn't cancel the checks
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/readyz")
+	s.serveRequest(ctx, parent)
+
+	if ctx.Response.StatusCode() != 200 {
+		t.Errorf("Expected 200 got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	// cancelled checks aren't cached
+	var runs int32
+	c := &healthCheck{HealthCheck: HealthCheck{
+		Check: func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 1 {
+				<-ctx.Done()
+			}
+			return ctx.Err()
+		},
+		Timeout:  time.Second,
+		CacheFor: time.Minute,
+	}}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := c.run(cancelled); err != context.Canceled {
+		t.Errorf("Expected %v got %v", context.Canceled, err)
+	}
+
+	if err := c.run(context.Background()); err != nil {
+		t.Errorf("Expected cancelled result not to be cached got %v", err)
+	}
+}
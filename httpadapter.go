@@ -0,0 +1,170 @@
+package supernova
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// HTTPHandler adapts a net/http handler to a route function. Route params are
+// available through http.Request.PathValue and the request context is Request.Ctx.
+func HTTPHandler(h http.Handler) func(*Request) {
+	return func(req *Request) {
+		handler := fasthttpadaptor.NewFastHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(req.Ctx)
+			for k, v := range req.routeParams {
+				r.SetPathValue(k, v)
+			}
+
+			h.ServeHTTP(w, r)
+		}))
+
+		handler(req.RequestCtx)
+	}
+}
+
+// HTTPMiddleware adapts net/http middleware to be added with Use. Headers the
+// middleware sets before calling the next handler are kept, changes it makes
+// to the request headers and context are seen by later handlers, and a
+// response it writes without calling next ends the request. When the middleware
+// wraps the ResponseWriter, for example to compress the body, the response from
+// later handlers is written through the wrapper once they return so streamed
+// bodies are buffered.
+func HTTPMiddleware(mw func(http.Handler) http.Handler) func(*Request, func()) {
+	return func(req *Request, next func()) {
+		r := new(http.Request)
+		err := fasthttpadaptor.ConvertRequest(req.RequestCtx, r, true)
+		if err != nil {
+			req.Error(fasthttp.StatusBadRequest, "Bad Request")
+			return
+		}
+		r = r.WithContext(req.Ctx)
+		original := r.Header.Clone()
+
+		w := &responseWriter{req: req, header: make(http.Header)}
+		mw(http.HandlerFunc(func(dw http.ResponseWriter, r *http.Request) {
+			w.copyHeader()
+			req.Ctx = r.Context()
+
+			for k := range original {
+				req.Request.Header.Del(k)
+			}
+			for k, vv := range r.Header {
+				for _, v := range vv {
+					req.Request.Header.Add(k, v)
+				}
+			}
+
+			next()
+
+			if dw != http.ResponseWriter(w) && !req.TimedOut() {
+				writeThrough(req, dw)
+			}
+		})).ServeHTTP(w, r)
+	}
+}
+
+// writeThrough moves the response written by later handlers to a
+// ResponseWriter wrapping the middleware's writer
+func writeThrough(req *Request, w http.ResponseWriter) {
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.Response.CopyTo(resp)
+	req.Response.Reset()
+
+	// the wrapper may change the body so the length is left for it to set
+	resp.Header.Del(fasthttp.HeaderContentLength)
+	for k := range w.Header() {
+		if resp.Header.Peek(k) != nil {
+			w.Header().Del(k)
+		}
+	}
+	resp.Header.VisitAll(func(k, v []byte) {
+		w.Header().Add(string(k), string(v))
+	})
+
+	w.WriteHeader(resp.StatusCode())
+	resp.BodyWriteTo(w)
+}
+
+// responseWriter writes a net/http response to the Request's response
+type responseWriter struct {
+	req         *Request
+	header      http.Header
+	wroteHeader bool
+}
+
+// Header returns the headers to be sent
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sends the headers with the status code
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	w.copyHeader()
+	w.req.Response.SetStatusCode(code)
+}
+
+// Write appends to the response body
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(fasthttp.StatusOK)
+	return w.req.Write(p)
+}
+
+// copyHeader sets the headers on the response
+func (w *responseWriter) copyHeader() {
+	for k, vv := range w.header {
+		w.req.Response.Header.Del(k)
+		for _, v := range vv {
+			w.req.Response.Header.Add(k, v)
+		}
+	}
+}
+
+// ServeHTTP lets the Server be used as a net/http handler, for example with httptest
+func (sn *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := new(fasthttp.Request)
+
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+	req.Header.SetHost(r.Host)
+	for k, vv := range r.Header {
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		req.SetBody(body)
+	}
+
+	var remoteAddr net.Addr
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		remoteAddr = addr
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(req, remoteAddr, sn.server.Logger)
+
+	// after a timeout the route may still be writing to ctx.Response so only
+	// the response that was sent is copied
+	resp := sn.serveRequest(ctx, r.Context())
+
+	resp.Header.VisitAll(func(k, v []byte) {
+		w.Header().Add(string(k), string(v))
+	})
+	w.WriteHeader(resp.StatusCode())
+	resp.BodyWriteTo(w)
+}
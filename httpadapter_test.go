@@ -0,0 +1,186 @@
+package supernova
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type adapterKey struct{}
+
+func TestHTTPHandler(t *testing.T) {
+	s := New()
+	s.Get("/users/:id", HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User", r.PathValue("id"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})))
+
+	resp, err := doRequest(s, "GET /users/7 HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 201 || string(resp.Body()) != "created" {
+		t.Errorf("Expected 201 created got %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	if v := string(resp.Header.Peek("X-User")); v != "7" {
+		t.Errorf("Expected route param 7 got %s", v)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	s := New()
+	s.Use(HTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Token") != "secret" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("X-Middleware", "yes")
+			r.Header.Del("X-Token")
+			r.Header.Set("X-User", "gopher")
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adapterKey{}, "value")))
+		})
+	}))
+	s.Get("/test", func(r *Request) {
+		r.Send(string(r.Request.Header.Peek("X-User")) + ":" + string(r.Request.Header.Peek("X-Token")) + ":" + r.Ctx.Value(adapterKey{}).(string))
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 403 {
+		t.Errorf("Expected 403 got %d", resp.StatusCode())
+	}
+
+	resp, err = doRequest(s, "GET /test HTTP/1.1\r\nHost: localhost\r\nX-Token: secret\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "gopher::value" {
+		t.Errorf("Expected gopher::value got %s", resp.Body())
+	}
+
+	if v := string(resp.Header.Peek("X-Middleware")); v != "yes" {
+		t.Errorf("Expected middleware header got %q", v)
+	}
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	return w.gz.Write(p)
+}
+
+func TestHTTPMiddleware_WrappedWriter(t *testing.T) {
+	s := New()
+	s.Use(HTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, gz: gz}, r)
+		})
+	}))
+	s.Get("/test", func(r *Request) {
+		r.Response.Header.Set("X-Route", "yes")
+		r.Response.SetStatusCode(201)
+		r.Send("hello world")
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 201 {
+		t.Errorf("Expected 201 got %d", resp.StatusCode())
+	}
+
+	if v := string(resp.Header.Peek("X-Route")); v != "yes" {
+		t.Errorf("Expected route header got %q", v)
+	}
+
+	if v := string(resp.Header.Peek("Content-Encoding")); v != "gzip" {
+		t.Errorf("Expected gzip got %q", v)
+	}
+
+	body, err := resp.BodyGunzip()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "hello world" {
+		t.Errorf("Expected hello world got %q", body)
+	}
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	s := New()
+	s.Post("/echo/:name", func(r *Request) {
+		r.Response.Header.Set("X-Name", r.RouteParam("name"))
+		r.Send(string(r.Request.Body()))
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/echo/gopher", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "hello" {
+		t.Errorf("Expected 200 hello got %d %s", resp.StatusCode, body)
+	}
+
+	if v := resp.Header.Get("X-Name"); v != "gopher" {
+		t.Errorf("Expected gopher got %s", v)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != 404 {
+		t.Errorf("Expected 404 got %d", rec.Code)
+	}
+}
+
+func TestServer_ServeHTTP_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	s := New()
+	s.Get("/slow", func(r *Request) {
+		defer close(done)
+		<-r.Ctx.Done()
+		for i := 0; i < 100; i++ {
+			r.Write([]byte("late"))
+		}
+	}).Timeout(10 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	<-done
+
+	if rec.Code != 503 {
+		t.Errorf("Expected 503 got %d", rec.Code)
+	}
+
+	if body := rec.Body.String(); strings.Contains(body, "late") {
+		t.Errorf("Expected the timeout response got %q", body)
+	}
+}
@@ -0,0 +1,297 @@
+package supernova
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Errors returned when a JWT fails verification
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidAudience  = errors.New("token audience is not accepted")
+)
+
+// JWTConfig holds the keys and claim checks used to verify tokens
+type JWTConfig struct {
+	// Secret verifies HS256 signatures, HS256 tokens are rejected when it is empty
+	Secret []byte
+
+	// PublicKey verifies RS256 (*rsa.PublicKey) or ES256 (*ecdsa.PublicKey) signatures
+	PublicKey crypto.PublicKey
+
+	// KeySet provides keys selected by the kid header of the token
+	KeySet *JWKS
+
+	// Audience must be present in the aud claim when set
+	Audience string
+
+	// Leeway is the allowed clock skew when checking exp and nbf
+	Leeway time.Duration
+}
+
+// JWTAuth returns middleware requiring a Bearer JWT that passes VerifyJWT
+func JWTAuth(config JWTConfig) func(*Request, func()) {
+	return BearerAuth(func(token string) (*Principal, error) {
+		return VerifyJWT(token, config)
+	})
+}
+
+// VerifyJWT checks the signature and exp, nbf and aud claims of token and
+// returns a Principal named after the sub claim
+func VerifyJWT(token string, config JWTConfig) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	key := config.key(header.Alg, header.Kid)
+	if key == nil {
+		return nil, ErrUnsupportedAlg
+	}
+
+	err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(unixTime(exp).Add(config.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(config.Leeway).Before(unixTime(nbf)) {
+		return nil, ErrTokenNotValidYet
+	}
+
+	if config.Audience != "" && !hasAudience(claims["aud"], config.Audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	sub, _ := claims["sub"].(string)
+	return &Principal{Name: sub, Scheme: "jwt", Claims: claims}, nil
+}
+
+// key returns the verification key for the algorithm or nil if none is configured
+func (c *JWTConfig) key(alg, kid string) interface{} {
+	var key interface{}
+	if c.KeySet != nil {
+		key = c.KeySet.Key(kid)
+	}
+
+	if key == nil {
+		switch alg {
+		case "HS256":
+			// an empty secret would let anyone sign tokens
+			if len(c.Secret) > 0 {
+				key = c.Secret
+			}
+		case "RS256", "ES256":
+			key = c.PublicKey
+		}
+	}
+
+	return key
+}
+
+// verifySignature checks sig over signed using the key required by alg
+func verifySignature(alg string, key interface{}, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return ErrUnsupportedAlg
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return ErrInvalidSignature
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+
+		if len(sig) != 64 {
+			return ErrInvalidSignature
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedAlg
+	}
+
+	return nil
+}
+
+// decodeSegment base64url decodes a token segment and unmarshals the JSON into v
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrMalformedToken
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformedToken
+	}
+
+	return nil
+}
+
+// hasAudience reports if the aud claim, a string or list of strings, contains audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// unixTime converts a NumericDate claim to a time
+func unixTime(sec float64) time.Time {
+	return time.Unix(int64(sec), 0)
+}
+
+// JWKS is a set of JSON Web Keys indexed by key id
+type JWKS struct {
+	keys map[string]interface{}
+}
+
+// LoadJWKS reads a JSON Web Key Set from a local file
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses a JSON Web Key Set supporting RSA, P-256 EC and symmetric keys
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &JWKS{keys: make(map[string]interface{})}
+	for _, k := range set.Keys {
+		var key interface{}
+		switch k.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil {
+				return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+			}
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				return nil, fmt.Errorf("unsupported curve %q for key %q", k.Crv, k.Kid)
+			}
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("invalid symmetric key %q", k.Kid)
+			}
+			key = secret
+		default:
+			continue
+		}
+
+		jwks.keys[k.Kid] = key
+	}
+
+	return jwks, nil
+}
+
+// Key returns the key with the given id or nil
+func (k *JWKS) Key(kid string) interface{} {
+	return k.keys[kid]
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
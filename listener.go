@@ -1,10 +1,15 @@
 package supernova
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/valyala/fasthttp"
 )
 
 // GracefulListener is used as custom listener to watch connections
@@ -16,13 +21,24 @@ type GracefulListener struct {
 	maxWaitTime time.Duration
 
 	// this channel is closed during graceful shutdown on zero open connections.
-	done chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
 
 	// the number of open connections
 	connsCount uint64
 
 	// becomes non-zero when graceful shutdown starts
 	shutdown uint64
+
+	// open connections so idle ones can be closed on shutdown
+	mutex sync.Mutex
+	conns map[*gracefulConn]struct{}
+
+	// logger reports shutdown progress when set
+	logger Logger
+
+	// middleWare runs for requests arriving on this listener
+	middleWare []Middleware
 }
 
 // NewGracefulListener wraps the given listener into 'graceful shutdown' listener.
@@ -31,6 +47,7 @@ func NewGracefulListener(ln net.Listener, maxWaitTime time.Duration) net.Listene
 		ln:          ln,
 		maxWaitTime: maxWaitTime,
 		done:        make(chan struct{}),
+		conns:       make(map[*gracefulConn]struct{}),
 	}
 }
 
@@ -41,20 +58,35 @@ func (ln *GracefulListener) Accept() (net.Conn, error) {
 		return nil, err
 	}
 	atomic.AddUint64(&ln.connsCount, 1)
-	return &gracefulConn{
+
+	conn := &gracefulConn{
 		Conn: c,
 		ln:   ln,
-	}, nil
+	}
+
+	ln.mutex.Lock()
+	ln.conns[conn] = struct{}{}
+	ln.mutex.Unlock()
+
+	return conn, nil
 }
 
 // Close closes the inner listener and waits until all the pending open connections
 // are closed before returning.
 func (ln *GracefulListener) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ln.maxWaitTime)
+	defer cancel()
+	return ln.Shutdown(ctx)
+}
+
+// Shutdown closes the inner listener, closes idle keep-alive connections and
+// waits until all the pending open connections are closed or the context is done.
+func (ln *GracefulListener) Shutdown(ctx context.Context) error {
 	err := ln.ln.Close()
 	if err != nil {
-		return nil
+		return err
 	}
-	return ln.waitForZeroConns()
+	return ln.waitForZeroConns(ctx)
 }
 
 // Addr returns the listener's network address.
@@ -62,27 +94,96 @@ func (ln *GracefulListener) Addr() net.Addr {
 	return ln.ln.Addr()
 }
 
-func (ln *GracefulListener) waitForZeroConns() error {
+// ActiveConnections returns the number of open connections
+func (ln *GracefulListener) ActiveConnections() uint64 {
+	return atomic.LoadUint64(&ln.connsCount)
+}
+
+func (ln *GracefulListener) waitForZeroConns(ctx context.Context) error {
 	atomic.AddUint64(&ln.shutdown, 1)
-	fmt.Printf("Waiting on %d connections\n", ln.connsCount)
+
+	idle := ln.closeIdleConns()
+	ln.log(slog.LevelInfo, "Closed idle connections", "idle", idle)
+
+	if open := ln.ActiveConnections(); open > 0 {
+		ln.log(slog.LevelInfo, "Waiting on connections", "open", open)
+	} else {
+		ln.closeDone()
+	}
+
 	select {
 	case <-ln.done:
+		ln.log(slog.LevelInfo, "All connections closed")
 		return nil
-	case <-time.After(ln.maxWaitTime):
-		return fmt.Errorf("cannot complete graceful shutdown in %s", ln.maxWaitTime)
+	case <-ctx.Done():
+		ln.log(slog.LevelWarn, "Gave up waiting on connections", "open", ln.ActiveConnections())
+		return fmt.Errorf("cannot complete graceful shutdown: %w", ctx.Err())
+	}
+}
+
+// closeIdleConns closes connections waiting for their next request and
+// returns how many were closed
+func (ln *GracefulListener) closeIdleConns() int {
+	ln.mutex.Lock()
+	var idle []*gracefulConn
+	for c := range ln.conns {
+		if c.isIdle() {
+			idle = append(idle, c)
+		}
+	}
+	ln.mutex.Unlock()
+
+	for _, c := range idle {
+		c.Close()
 	}
+
+	return len(idle)
 }
 
-func (ln *GracefulListener) closeConn() {
+func (ln *GracefulListener) closeConn(c *gracefulConn) {
+	ln.mutex.Lock()
+	delete(ln.conns, c)
+	ln.mutex.Unlock()
+
 	connsCount := atomic.AddUint64(&ln.connsCount, ^uint64(0))
 	if atomic.LoadUint64(&ln.shutdown) != 0 && connsCount == 0 {
+		ln.closeDone()
+	}
+}
+
+// closeDone signals that all connections are closed
+func (ln *GracefulListener) closeDone() {
+	ln.doneOnce.Do(func() {
 		close(ln.done)
+	})
+}
+
+// log writes to the logger if one is set, adding the listener's address
+func (ln *GracefulListener) log(level slog.Level, msg string, args ...interface{}) {
+	if ln.logger == nil {
+		return
+	}
+
+	args = append(args, "addr", ln.Addr().String())
+	if level == slog.LevelWarn {
+		ln.logger.Warn(msg, args...)
+		return
 	}
+
+	ln.logger.Info(msg, args...)
 }
 
 type gracefulConn struct {
 	net.Conn
 	ln *GracefulListener
+
+	// idle is non-zero while the connection waits for the next request
+	idle int32
+}
+
+// NetConn returns the wrapped connection
+func (c *gracefulConn) NetConn() net.Conn {
+	return c.Conn
 }
 
 // Close starts listener shutdown
@@ -91,6 +192,45 @@ func (c *gracefulConn) Close() error {
 	if err != nil {
 		return err
 	}
-	c.ln.closeConn()
+	c.ln.closeConn(c)
 	return nil
 }
+
+// isIdle reports if the connection is between requests
+func (c *gracefulConn) isIdle() bool {
+	return atomic.LoadInt32(&c.idle) == 1
+}
+
+// trackConnState records keep-alive state changes reported by fasthttp and
+// closes connections going idle once shutdown has started
+func trackConnState(c net.Conn, state fasthttp.ConnState) {
+	gc := findGracefulConn(c)
+	if gc == nil {
+		return
+	}
+
+	if state != fasthttp.StateIdle {
+		atomic.StoreInt32(&gc.idle, 0)
+		return
+	}
+
+	atomic.StoreInt32(&gc.idle, 1)
+	if atomic.LoadUint64(&gc.ln.shutdown) != 0 {
+		gc.Close()
+	}
+}
+
+// findGracefulConn unwraps c until the graceful connection is found
+func findGracefulConn(c net.Conn) *gracefulConn {
+	for {
+		if gc, ok := c.(*gracefulConn); ok {
+			return gc
+		}
+
+		u, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		c = u.NetConn()
+	}
+}
@@ -0,0 +1,132 @@
+package supernova
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+)
+
+// ListenerConfig describes a listener served by ListenAndServeAll
+type ListenerConfig struct {
+	// Network is tcp or unix, defaults to tcp
+	Network string
+	Addr    string
+
+	// Mode is the file mode of a unix socket, defaults to 0666
+	Mode os.FileMode
+
+	// TLS serves the listener over TLS using the server TLS config and
+	// certificates. CertFile and KeyFile are added to them when set.
+	TLS      bool
+	CertFile string
+	KeyFile  string
+
+	// Middleware runs only for requests arriving on this listener, before
+	// the middleware added with Use
+	Middleware []func(*Request, func())
+}
+
+// AddListener adds a listener to be served by ListenAndServeAll
+func (sn *Server) AddListener(config ListenerConfig) {
+	sn.configured = append(sn.configured, config)
+}
+
+// ListenAndServeAll binds every listener added with AddListener and serves
+// them concurrently. If any listener fails the others are shut down and the
+// error is returned. After Shutdown is called it returns nil.
+func (sn *Server) ListenAndServeAll() error {
+	if len(sn.configured) == 0 {
+		return errors.New("no listeners added")
+	}
+
+	var gls []*GracefulListener
+	var lns []net.Listener
+	closeAll := func() {
+		for _, gl := range gls {
+			gl.ln.Close()
+		}
+	}
+
+	for _, config := range sn.configured {
+		ln, err := sn.listenConfig(config)
+		if err != nil {
+			closeAll()
+			return err
+		}
+
+		gl := sn.newGracefulListener(ln)
+		for _, f := range config.Middleware {
+			gl.middleWare = append(gl.middleWare, Middleware{middleFunc: f, name: funcName(f)})
+		}
+		gls = append(gls, gl)
+
+		if !config.TLS {
+			lns = append(lns, gl)
+			continue
+		}
+
+		tlsConfig, err := sn.buildTLSConfig(config.CertFile, config.KeyFile)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		lns = append(lns, tls.NewListener(gl, tlsConfig))
+	}
+
+	return sn.serveAll(gls, lns)
+}
+
+// listenConfig opens the listener described by config
+func (sn *Server) listenConfig(config ListenerConfig) (net.Listener, error) {
+	if config.Network == "unix" {
+		mode := config.Mode
+		if mode == 0 {
+			mode = 0666
+		}
+
+		return sn.listenUnix(config.Addr, mode)
+	}
+
+	network := config.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	return sn.listen(network, config.Addr)
+}
+
+// serveAll serves each listener in lns, which are the graceful listeners in gls
+// or listeners wrapping them, and shuts all of them down once one fails
+func (sn *Server) serveAll(gls []*GracefulListener, lns []net.Listener) error {
+	// track listeners in order so a restart hands them over in the same order
+	for _, gl := range gls {
+		sn.addListener(gl)
+	}
+
+	errs := make(chan error, len(lns))
+	for i := range lns {
+		go func(gl *GracefulListener, ln net.Listener) {
+			errs <- sn.serve(gl, ln)
+		}(gls[i], lns[i])
+	}
+
+	var err error
+	for range lns {
+		e := <-errs
+		if e == nil || err != nil {
+			continue
+		}
+
+		err = e
+		sn.logger.Error("Listener failed, shutting down the others", "err", err)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), sn.drainTimeout)
+			defer cancel()
+			sn.Shutdown(ctx)
+		}()
+	}
+
+	return err
+}
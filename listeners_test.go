@@ -0,0 +1,106 @@
+package supernova
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_ListenAndServeAll(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.AddListener(ListenerConfig{Addr: "127.0.0.1:0"})
+	s.AddListener(ListenerConfig{
+		Addr: "127.0.0.1:0",
+		Middleware: []func(*Request, func()){
+			func(r *Request, next func()) {
+				r.Response.Header.Set("X-Listener", "admin")
+				next()
+			},
+		},
+	})
+
+	s.Get("/test", func(r *Request) {
+		r.Send("ok")
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ListenAndServeAll()
+	}()
+
+	waitForListener(t, s)
+	listeners := s.servedListeners()
+	for i := 0; i < 100 && len(listeners) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		listeners = s.servedListeners()
+	}
+
+	if len(listeners) != 2 {
+		t.Fatalf("Expected 2 listeners got %d", len(listeners))
+	}
+
+	for i, expected := range []string{"", "admin"} {
+		resp, err := http.Get("http://" + listeners[i].Addr().String() + "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != "ok" {
+			t.Errorf("Expected ok got %q", body)
+		}
+
+		if v := resp.Header.Get("X-Listener"); v != expected {
+			t.Errorf("Expected listener %d header %q got %q", i, expected, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected ListenAndServeAll to return nil got %v", err)
+	}
+}
+
+func TestServer_ListenAndServeAll_BindError(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.AddListener(ListenerConfig{Addr: "127.0.0.1:0"})
+	s.AddListener(ListenerConfig{Addr: "invalid address"})
+
+	if err := s.ListenAndServeAll(); err == nil {
+		t.Error("Expected bind error")
+	}
+
+	if n := len(s.servedListeners()); n != 0 {
+		t.Errorf("Expected no listeners served got %d", n)
+	}
+}
+
+func TestServer_Serve_RemovesListener(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+
+	ln := NewMemoryListener()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln)
+	}()
+	waitForListener(t, s)
+
+	ln.Close()
+	<-served
+
+	if n := len(s.servedListeners()); n != 0 {
+		t.Errorf("Expected closed listener to be removed got %d listeners", n)
+	}
+}
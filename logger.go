@@ -1,90 +1,47 @@
-// Copyright 2014 Manu Martinez-Almeida.  All rights reserved.
-// Use of this source code is governed by a MIT style
-// license that can be found in the LICENSE file.
-// borrowed from Gin library
-
 package supernova
 
 import (
 	"fmt"
-	"os"
-	"time"
-
-	"golang.org/x/crypto/ssh/terminal"
-)
-
-var (
-	green   = string([]byte{27, 91, 57, 55, 59, 52, 50, 109})
-	white   = string([]byte{27, 91, 57, 48, 59, 52, 55, 109})
-	yellow  = string([]byte{27, 91, 57, 55, 59, 52, 51, 109})
-	red     = string([]byte{27, 91, 57, 55, 59, 52, 49, 109})
-	blue    = string([]byte{27, 91, 57, 55, 59, 52, 52, 109})
-	magenta = string([]byte{27, 91, 57, 55, 59, 52, 53, 109})
-	cyan    = string([]byte{27, 91, 57, 55, 59, 52, 54, 109})
-	reset   = string([]byte{27, 91, 48, 109})
+	"log/slog"
 )
 
-func getDebugMethod(r *Request) func() {
-	// Start timer
-	start := time.Now()
-	return func() {
-		path := r.URI().Path()
-
-		// Stop timer
-		end := time.Now()
-		latency := end.Sub(start)
+// Logger receives the server's own diagnostics such as shutdown progress,
+// panics and listener errors. Messages are followed by alternating keys and
+// values. *slog.Logger satisfies Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
 
-		clientIP := r.RemoteIP().String()
-		method := r.GetMethod()
-		statusCode := r.Response.StatusCode()
+// defaultLogger writes diagnostics to the default slog logger
+func defaultLogger() Logger {
+	return slog.Default().With("component", "supernova")
+}
 
-		var statusColor, methodColor string
-		if terminal.IsTerminal(int(os.Stdout.Fd())) {
-			statusColor = colorForStatus(statusCode)
-			methodColor = colorForMethod(method)
-		}
+// SetLogger sets the logger used for the server's diagnostics and fasthttp's
+// errors. It must be called before serving.
+func (sn *Server) SetLogger(logger Logger) {
+	sn.logger = logger
+	sn.server.Logger = fasthttpLogger{logger}
 
-		fmt.Printf("[Supernova] %v |%s %3d %s| %13v | %s |%s  %s %-7s %s\n",
-			end.Format("2006/01/02 - 15:04:05"),
-			statusColor, statusCode, reset,
-			latency,
-			clientIP,
-			methodColor, reset, method,
-			path,
-		)
+	if sn.certs != nil {
+		sn.certs.logger = logger
 	}
 }
 
-func colorForStatus(code int) string {
-	switch {
-	case code >= 200 && code < 300:
-		return green
-	case code >= 300 && code < 400:
-		return white
-	case code >= 400 && code < 500:
-		return yellow
-	default:
-		return red
-	}
+// SetLogHandler sets a slog handler to write the server's diagnostics
+func (sn *Server) SetLogHandler(handler slog.Handler) {
+	sn.SetLogger(slog.New(handler))
 }
 
-func colorForMethod(method string) string {
-	switch method {
-	case "GET":
-		return blue
-	case "POST":
-		return cyan
-	case "PUT":
-		return yellow
-	case "DELETE":
-		return red
-	case "PATCH":
-		return green
-	case "HEAD":
-		return magenta
-	case "OPTIONS":
-		return white
-	default:
-		return reset
-	}
+// fasthttpLogger passes fasthttp's messages to a Logger as errors
+type fasthttpLogger struct {
+	logger Logger
+}
+
+// Printf logs the formatted message
+func (l fasthttpLogger) Printf(format string, args ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, args...), "source", "fasthttp")
 }
@@ -0,0 +1,43 @@
+package supernova
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestServer_SetLogHandler_Panic(t *testing.T) {
+	var logs bytes.Buffer
+
+	s := New()
+	s.SetLogHandler(slog.NewTextHandler(&logs, nil))
+
+	// middleware wrapping the route still runs and sees the 500
+	var status int
+	s.Use(func(r *Request, next func()) {
+		next()
+		status = r.Response.StatusCode()
+	})
+	s.Get("/panic", func(r *Request) {
+		panic("boom")
+	})
+
+	resp, err := doRequest(s, "GET /panic HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 500 {
+		t.Errorf("Expected 500 got %d", resp.StatusCode())
+	}
+
+	if status != 500 {
+		t.Errorf("Expected middleware to see 500 got %d", status)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "panic=boom") {
+		t.Errorf("Expected panic to be logged got %q", out)
+	}
+}
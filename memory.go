@@ -0,0 +1,105 @@
+package supernova
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// MemoryListener is a net.Listener for in-process connections. Clients
+// connect with Dial and each connection is a synchronous in-memory pipe.
+type MemoryListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// memoryAddr is the address of a MemoryListener
+type memoryAddr struct{}
+
+// Network returns the network name
+func (memoryAddr) Network() string {
+	return "memory"
+}
+
+// String returns the address
+func (memoryAddr) String() string {
+	return "memory"
+}
+
+// NewMemoryListener returns a MemoryListener ready to be served
+func NewMemoryListener() *MemoryListener {
+	return &MemoryListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for a client to Dial
+func (ln *MemoryListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-ln.conns:
+		return c, nil
+	case <-ln.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections. Connections already accepted stay open.
+func (ln *MemoryListener) Close() error {
+	ln.closeOnce.Do(func() {
+		close(ln.done)
+	})
+	return nil
+}
+
+// Addr returns the listener's address
+func (ln *MemoryListener) Addr() net.Addr {
+	return memoryAddr{}
+}
+
+// Dial connects to the listener, waiting for the connection to be accepted
+func (ln *MemoryListener) Dial() (net.Conn, error) {
+	return ln.DialContext(context.Background(), "memory", "memory")
+}
+
+// DialContext connects to the listener ignoring the network and address so it
+// can be used as net/http's Transport.DialContext
+func (ln *MemoryListener) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	server, client := net.Pipe()
+
+	select {
+	case ln.conns <- server:
+		return client, nil
+	case <-ln.done:
+	case <-ctx.Done():
+		server.Close()
+		client.Close()
+		return nil, ctx.Err()
+	}
+
+	server.Close()
+	client.Close()
+	return nil, &net.OpError{Op: "dial", Net: "memory", Addr: memoryAddr{}, Err: net.ErrClosed}
+}
+
+// Dialer returns a dial function for fasthttp.Client connecting to the listener
+func (ln *MemoryListener) Dialer() func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		return ln.Dial()
+	}
+}
+
+// MemoryListener returns an in-memory listener served by the server, starting
+// it on the first call. Every call returns the same listener.
+func (sn *Server) MemoryListener() *MemoryListener {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	if sn.memory == nil {
+		sn.memory = NewMemoryListener()
+		go sn.Serve(sn.memory)
+	}
+
+	return sn.memory
+}
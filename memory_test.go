@@ -0,0 +1,52 @@
+package supernova
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMemoryListener(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/test", func(r *Request) {
+		r.Send("in memory")
+	})
+
+	ln := NewMemoryListener()
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln)
+	}()
+
+	client := &fasthttp.Client{Dial: ln.Dialer()}
+	code, body, err := client.Get(nil, "http://memory/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != 200 || string(body) != "in memory" {
+		t.Errorf("Expected 200 in memory got %d %s", code, body)
+	}
+
+	if n := s.ActiveConnections(); n != 1 {
+		t.Errorf("Expected 1 active connection got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected Serve to return nil got %v", err)
+	}
+
+	if _, err := ln.Dial(); err == nil {
+		t.Error("Expected dial after shutdown to fail")
+	}
+}
@@ -0,0 +1,228 @@
+package supernova
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request duration histogram
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// sizeBuckets are the upper bounds in bytes of the response size histogram
+var sizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// Metrics records request and connection metrics and exposes them in the
+// Prometheus text format
+type Metrics struct {
+	server *Server
+
+	inFlight int64
+
+	mutex    sync.Mutex
+	requests map[metricLabels]*requestMetrics
+}
+
+// metricLabels identify a series of request metrics
+type metricLabels struct {
+	method string
+	route  string
+	status string
+}
+
+// requestMetrics holds the counters of one series
+type requestMetrics struct {
+	count   uint64
+	latency histogram
+	size    histogram
+}
+
+// histogram counts observations per bucket
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// EnableMetrics instruments every request and returns the Metrics so they can
+// be served with Get("/metrics", metrics.Handler). Call it before Use so
+// requests rejected by other middleware are counted.
+func (sn *Server) EnableMetrics() *Metrics {
+	m := &Metrics{
+		server:   sn,
+		requests: make(map[metricLabels]*requestMetrics),
+	}
+
+	sn.Use(m.middleware)
+	return m
+}
+
+// middleware records the request once it's been handled
+func (m *Metrics) middleware(req *Request, next func()) {
+	start := time.Now()
+	atomic.AddInt64(&m.inFlight, 1)
+	defer atomic.AddInt64(&m.inFlight, -1)
+
+	next()
+
+	route := req.RoutePattern()
+	if route == "" {
+		// raw paths would give every unknown URL its own series
+		route = "unmatched"
+	}
+
+	labels := metricLabels{
+		method: methodLabel(req.GetMethod()),
+		route:  route,
+		status: statusClass(req.responseStatus()),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rm := m.requests[labels]
+	if rm == nil {
+		rm = &requestMetrics{
+			latency: histogram{counts: make([]uint64, len(latencyBuckets))},
+			size:    histogram{counts: make([]uint64, len(sizeBuckets))},
+		}
+		m.requests[labels] = rm
+	}
+
+	rm.count++
+	rm.latency.observe(latencyBuckets, time.Since(start).Seconds())
+	rm.size.observe(sizeBuckets, float64(req.responseSize()))
+}
+
+// Handler writes the metrics in the Prometheus text format
+func (m *Metrics) Handler(req *Request) {
+	req.Response.Header.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+	req.Write(m.export())
+}
+
+// export formats every metric in the Prometheus text format
+func (m *Metrics) export() []byte {
+	var b bytes.Buffer
+
+	m.mutex.Lock()
+	keys := make([]metricLabels, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, c := keys[i], keys[j]
+		if a.route != c.route {
+			return a.route < c.route
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+
+	b.WriteString("# HELP supernova_requests_total Requests handled.\n")
+	b.WriteString("# TYPE supernova_requests_total counter\n")
+	for _, k := range keys {
+		b.WriteString("supernova_requests_total" + k.String("") + " " + strconv.FormatUint(m.requests[k].count, 10) + "\n")
+	}
+
+	b.WriteString("# HELP supernova_request_duration_seconds Time taken to handle requests.\n")
+	b.WriteString("# TYPE supernova_request_duration_seconds histogram\n")
+	for _, k := range keys {
+		m.requests[k].latency.write(&b, "supernova_request_duration_seconds", k, latencyBuckets, m.requests[k].count)
+	}
+
+	b.WriteString("# HELP supernova_response_size_bytes Size of response bodies.\n")
+	b.WriteString("# TYPE supernova_response_size_bytes histogram\n")
+	for _, k := range keys {
+		m.requests[k].size.write(&b, "supernova_response_size_bytes", k, sizeBuckets, m.requests[k].count)
+	}
+	m.mutex.Unlock()
+
+	b.WriteString("# HELP supernova_requests_in_flight Requests being handled.\n")
+	b.WriteString("# TYPE supernova_requests_in_flight gauge\n")
+	b.WriteString("supernova_requests_in_flight " + strconv.FormatInt(atomic.LoadInt64(&m.inFlight), 10) + "\n")
+
+	b.WriteString("# HELP supernova_open_connections Open connections per listener.\n")
+	b.WriteString("# TYPE supernova_open_connections gauge\n")
+	for _, ln := range m.server.servedListeners() {
+		b.WriteString("supernova_open_connections{listener=\"" + escapeLabel(ln.Addr().String()) + "\"} ")
+		b.WriteString(strconv.FormatUint(ln.ActiveConnections(), 10) + "\n")
+	}
+
+	return b.Bytes()
+}
+
+// String formats the labels with an optional le label for histogram buckets
+func (l metricLabels) String(le string) string {
+	s := "{method=\"" + escapeLabel(l.method) + "\",route=\"" + escapeLabel(l.route) + "\",status=\"" + l.status + "\""
+	if le != "" {
+		s += ",le=\"" + le + "\""
+	}
+
+	return s + "}"
+}
+
+// observe counts v in the first bucket it fits, cumulative counts are built on export
+func (h *histogram) observe(buckets []float64, v float64) {
+	h.sum += v
+	for i, upper := range buckets {
+		if v <= upper {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// write formats the histogram series for the labels
+func (h *histogram) write(b *bytes.Buffer, name string, labels metricLabels, buckets []float64, count uint64) {
+	var cumulative uint64
+	for i, upper := range buckets {
+		cumulative += h.counts[i]
+		b.WriteString(name + "_bucket" + labels.String(formatFloat(upper)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+	}
+
+	b.WriteString(name + "_bucket" + labels.String("+Inf") + " " + strconv.FormatUint(count, 10) + "\n")
+	b.WriteString(name + "_sum" + labels.String("") + " " + formatFloat(h.sum) + "\n")
+	b.WriteString(name + "_count" + labels.String("") + " " + strconv.FormatUint(count, 10) + "\n")
+}
+
+// formatFloat formats a sample value
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values as required by the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// methodLabel returns the method for standard methods and OTHER for the rest
+// so clients can't create unlimited series
+func methodLabel(method string) string {
+	switch method {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodPost, fasthttp.MethodPut,
+		fasthttp.MethodPatch, fasthttp.MethodDelete, fasthttp.MethodConnect,
+		fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return method
+	}
+
+	return "OTHER"
+}
+
+// statusClass returns the class of a status code such as 2xx
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(code/100) + "xx"
+}
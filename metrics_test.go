@@ -0,0 +1,55 @@
+package supernova
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServer_EnableMetrics(t *testing.T) {
+	s := New()
+	metrics := s.EnableMetrics()
+	s.Get("/users/:id", func(r *Request) {
+		r.Send("hello")
+	})
+	s.Get("/metrics", metrics.Handler)
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		if err := sendRequest(s, "GET", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sendRequest(s, "MADEUP", "/users/3"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := doRequest(s, "GET /metrics HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := string(resp.Body())
+	expected := []string{
+		`supernova_requests_total{method="GET",route="/users/:id",status="2xx"} 2`,
+		`supernova_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+		`supernova_request_duration_seconds_count{method="GET",route="/users/:id",status="2xx"} 2`,
+		`supernova_response_size_bytes_bucket{method="GET",route="/users/:id",status="2xx",le="100"} 2`,
+		`supernova_response_size_bytes_sum{method="GET",route="/users/:id",status="2xx"} 10`,
+		`supernova_requests_total{method="OTHER",route="unmatched",status="4xx"} 1`,
+		`supernova_requests_in_flight 1`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Errorf("Expected %s in metrics got\n%s", e, body)
+		}
+	}
+
+	if strings.Contains(body, "MADEUP") {
+		t.Error("Expected non-standard methods to be labelled OTHER")
+	}
+
+	if strings.Contains(body, "/users/1") {
+		t.Error("Expected raw paths not to be used as labels")
+	}
+}
@@ -0,0 +1,313 @@
+package supernova
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm selects how requests are counted against the limit
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens evenly over Window and allows bursts up to Limit
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows Limit requests in any Window weighting the previous window
+	SlidingWindow
+)
+
+// RateLimit describes how many requests are allowed per window
+type RateLimit struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+}
+
+// RateLimitResult is the outcome of taking a request from a limit
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the quota is fully restored
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request would be allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore records requests per key. Implementations must be safe for
+// concurrent use and count each limit separately when shared by limiters.
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimitConfig holds the options for the rate limit middleware
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+
+	// Window defaults to one second
+	Window time.Duration
+
+	// Key returns the key requests are counted under, defaults to KeyByIP
+	Key func(*Request) string
+
+	// Store holds the counters, defaults to a new MemoryStore
+	Store RateLimitStore
+}
+
+// RateLimiter returns middleware that rejects requests over the limit with a 429.
+// Requests are let through if the store returns an error. It panics if Limit
+// isn't positive.
+func RateLimiter(config RateLimitConfig) func(*Request, func()) {
+	if config.Limit <= 0 {
+		panic(fmt.Sprintf("supernova: rate limit must be positive, got %d", config.Limit))
+	}
+
+	if config.Key == nil {
+		config.Key = KeyByIP
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+
+	if config.Window <= 0 {
+		config.Window = time.Second
+	}
+
+	limit := RateLimit{
+		Algorithm: config.Algorithm,
+		Limit:     config.Limit,
+		Window:    config.Window,
+	}
+
+	return func(req *Request, next func()) {
+		res, err := config.Store.Take(config.Key(req), limit, time.Now())
+		if err != nil {
+			next()
+			return
+		}
+
+		header := &req.Response.Header
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			req.Error(429, "Too Many Requests")
+			return
+		}
+
+		next()
+	}
+}
+
+// KeyByIP counts requests per client IP
+func KeyByIP(req *Request) string {
+	return req.RemoteIP().String()
+}
+
+// KeyByHeader counts requests per value of the header, falling back to the client IP
+func KeyByHeader(name string) func(*Request) string {
+	return func(req *Request) string {
+		if v := req.Request.Header.Peek(name); len(v) > 0 {
+			return name + ":" + string(v)
+		}
+
+		return KeyByIP(req)
+	}
+}
+
+// KeyByPrincipal counts requests per authenticated principal, falling back to the client IP
+func KeyByPrincipal(req *Request) string {
+	if p := req.Principal(); p != nil {
+		return "principal:" + p.Scheme + ":" + p.Name
+	}
+
+	return KeyByIP(req)
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// memoryShards is the number of independently locked maps in a MemoryStore
+const memoryShards = 32
+
+// MemoryStore is an in-process RateLimitStore split into shards to reduce lock contention
+type MemoryStore struct {
+	shards [memoryShards]memoryShard
+}
+
+type memoryShard struct {
+	mutex     sync.Mutex
+	entries   map[string]*rateEntry
+	lastSweep time.Time
+}
+
+// rateEntry holds the state of one key for either algorithm
+type rateEntry struct {
+	// token bucket
+	tokens float64
+
+	// sliding window
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+
+	last   time.Time
+	window time.Duration
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	m := new(MemoryStore)
+	for i := range m.shards {
+		m.shards[i].entries = make(map[string]*rateEntry)
+	}
+
+	return m
+}
+
+// Take records a request for key and reports whether it's within limit. Keys
+// are counted separately for each limit so limiters can share a store.
+func (m *MemoryStore) Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	key = strconv.Itoa(int(limit.Algorithm)) + ":" + strconv.Itoa(limit.Limit) + ":" + limit.Window.String() + ":" + key
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &m.shards[h.Sum32()%memoryShards]
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	shard.sweep(now)
+
+	entry := shard.entries[key]
+	if entry == nil {
+		entry = &rateEntry{
+			tokens:      float64(limit.Limit),
+			windowStart: now.Truncate(limit.Window),
+			last:        now,
+		}
+		shard.entries[key] = entry
+	}
+	entry.window = limit.Window
+
+	if limit.Algorithm == SlidingWindow {
+		return entry.takeWindow(limit, now), nil
+	}
+
+	return entry.takeToken(limit, now), nil
+}
+
+// sweep drops entries that have been idle for longer than their window
+func (s *memoryShard) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if now.Sub(entry.last) > 2*entry.window {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// takeToken refills the bucket and takes a token if one is available
+func (e *rateEntry) takeToken(limit RateLimit, now time.Time) RateLimitResult {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		return deniedResult(limit)
+	}
+
+	max := float64(limit.Limit)
+	rate := max / limit.Window.Seconds()
+
+	e.tokens = math.Min(max, e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	res := RateLimitResult{Limit: limit.Limit}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsDuration((1 - e.tokens) / rate)
+	}
+
+	res.Remaining = int(e.tokens)
+	res.Reset = secondsDuration((max - e.tokens) / rate)
+	return res
+}
+
+// takeWindow estimates the requests in the trailing window and counts this one if allowed
+func (e *rateEntry) takeWindow(limit RateLimit, now time.Time) RateLimitResult {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		return deniedResult(limit)
+	}
+
+	window := limit.Window
+	start := now.Truncate(window)
+
+	switch {
+	case start.Sub(e.windowStart) >= 2*window:
+		e.prevCount, e.currCount = 0, 0
+	case start.After(e.windowStart):
+		e.prevCount, e.currCount = e.currCount, 0
+	}
+	e.windowStart = start
+	e.last = now
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(e.prevCount)*weight + float64(e.currCount)
+
+	res := RateLimitResult{
+		Limit: limit.Limit,
+		Reset: window - elapsed,
+	}
+
+	if estimate+1 <= float64(limit.Limit) {
+		e.currCount++
+		res.Allowed = true
+		res.Remaining = int(float64(limit.Limit) - estimate - 1)
+		return res
+	}
+
+	// find when the weighted count drops enough to allow one more request
+	free := float64(limit.Limit - 1)
+	if e.currCount <= limit.Limit-1 && e.prevCount > 0 {
+		at := 1 - (free-float64(e.currCount))/float64(e.prevCount)
+		res.RetryAfter = time.Duration(at*float64(window)) - elapsed
+	} else {
+		res.RetryAfter = window - elapsed
+		if e.currCount > 0 {
+			at := math.Max(0, 1-free/float64(e.currCount))
+			res.RetryAfter += time.Duration(at * float64(window))
+		}
+	}
+
+	return res
+}
+
+// deniedResult rejects every request for a limit that allows none
+func deniedResult(limit RateLimit) RateLimitResult {
+	return RateLimitResult{
+		Limit:      limit.Limit,
+		Reset:      limit.Window,
+		RetryAfter: limit.Window,
+	}
+}
+
+// secondsDuration converts fractional seconds to a duration
+func secondsDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
@@ -0,0 +1,153 @@
+package supernova
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	m := NewMemoryStore()
+	limit := RateLimit{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		res, _ := m.Take("a", limit, now)
+		if !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+
+	res, _ := m.Take("a", limit, now)
+	if res.Allowed {
+		t.Fatal("Expected bucket to be empty")
+	}
+
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected retry after 500ms got %s", res.RetryAfter)
+	}
+
+	// other keys have their own bucket
+	if res, _ := m.Take("b", limit, now); !res.Allowed {
+		t.Error("Expected other key to be allowed")
+	}
+
+	if res, _ := m.Take("a", limit, now.Add(500*time.Millisecond)); !res.Allowed {
+		t.Error("Expected bucket to refill")
+	}
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	m := NewMemoryStore()
+	limit := RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+	start := time.Now().Truncate(time.Minute)
+
+	for i := 0; i < 4; i++ {
+		res, _ := m.Take("a", limit, start.Add(time.Second))
+		if !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+
+	if res, _ := m.Take("a", limit, start.Add(2*time.Second)); res.Allowed {
+		t.Fatal("Expected window to be full")
+	}
+
+	// a quarter into the next window 3 of the previous 4 requests still count
+	res, _ := m.Take("a", limit, start.Add(75*time.Second))
+	if !res.Allowed {
+		t.Fatal("Expected request to be allowed in next window")
+	}
+
+	if res, _ := m.Take("a", limit, start.Add(76*time.Second)); res.Allowed {
+		t.Error("Expected weighted previous window to limit requests")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	s := New()
+	s.Use(RateLimiter(RateLimitConfig{
+		Limit:  1,
+		Window: time.Minute,
+		Key:    KeyByHeader("X-Api-Key"),
+	}))
+	s.Get("/test", func(r *Request) {
+		r.Send("ok")
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nX-Api-Key: a\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 || string(resp.Header.Peek("RateLimit-Remaining")) != "0" {
+		t.Errorf("Expected allowed request got %d remaining %q", resp.StatusCode(), resp.Header.Peek("RateLimit-Remaining"))
+	}
+
+	resp, err = doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nX-Api-Key: a\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 429 {
+		t.Errorf("Expected 429 got %d", resp.StatusCode())
+	}
+
+	if string(resp.Header.Peek("Retry-After")) != "60" {
+		t.Errorf("Expected Retry-After 60 got %q", resp.Header.Peek("Retry-After"))
+	}
+
+	resp, err = doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\nX-Api-Key: b\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 {
+		t.Errorf("Expected other key to be allowed got %d", resp.StatusCode())
+	}
+}
+
+func TestRateLimiter_InvalidLimit(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected RateLimiter to panic on a zero limit")
+		}
+	}()
+
+	RateLimiter(RateLimitConfig{})
+}
+
+func TestMemoryStore_ZeroLimit(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Now()
+
+	for _, algorithm := range []RateLimitAlgorithm{TokenBucket, SlidingWindow} {
+		res, _ := m.Take("a", RateLimit{Algorithm: algorithm, Window: time.Second}, now)
+		if res.Allowed {
+			t.Errorf("Expected algorithm %d to deny requests", algorithm)
+		}
+
+		if res.RetryAfter != time.Second {
+			t.Errorf("Expected retry after 1s got %s", res.RetryAfter)
+		}
+	}
+}
+
+func TestMemoryStore_SharedLimits(t *testing.T) {
+	m := NewMemoryStore()
+	strict := RateLimit{Algorithm: TokenBucket, Limit: 1, Window: time.Minute}
+	loose := RateLimit{Algorithm: SlidingWindow, Limit: 100, Window: time.Second}
+	now := time.Now()
+
+	if res, _ := m.Take("a", strict, now); !res.Allowed {
+		t.Fatal("Expected first request to be allowed")
+	}
+
+	// another limiter using the same key keeps its own count
+	if res, _ := m.Take("a", loose, now); !res.Allowed || res.Remaining != 99 {
+		t.Errorf("Expected separate count got allowed %t remaining %d", res.Allowed, res.Remaining)
+	}
+
+	if res, _ := m.Take("a", strict, now); res.Allowed {
+		t.Error("Expected strict limit to still apply")
+	}
+}
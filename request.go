@@ -1,13 +1,14 @@
 package supernova
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 
 	"github.com/valyala/fasthttp"
-	"golang.org/x/net/context"
 )
 
 // Request resembles an incoming request
@@ -18,16 +19,45 @@ type Request struct {
 	queryParams map[string]string
 	BaseUrl     string
 
-	// Writer is used to write to response body
+	// Writer is used to write to response body, which is buffered in memory.
+	// Use Stream or SendReader for large bodies.
 	Writer io.Writer
-	Ctx    context.Context
+
+	// Ctx is cancelled when the server shuts down, the client disconnects
+	// or the request has been handled
+	Ctx context.Context
+
+	// principal is set by the authentication middleware
+	principal *Principal
+
+	// requestID is set by the request ID middleware
+	requestID string
+
+	// span is set when tracing is enabled
+	span *Span
+
+	// server is the server handling the request or nil
+	server *Server
+
+	// locals holds values set by middleware for later handlers
+	locals map[string]interface{}
+
+	// timedOut is set once the timeout response has been sent
+	timedOut int32
+
+	// timeoutResponse is the response sent in place of the route's after a timeout
+	timeoutResponse *fasthttp.Response
+
+	// route is the route matched for the request
+	route *Route
 }
 
 // JSONError resembles the RESTful standard for an error response
 type JSONError struct {
-	Errors  []interface{} `json:"errors"`
-	Code    int           `json:"code"`
-	Message string        `json:"message"`
+	Errors    []interface{} `json:"errors"`
+	Code      int           `json:"code"`
+	Message   string        `json:"message"`
+	RequestID string        `json:"requestId,omitempty"`
 }
 
 // JSONErrors holds the JSONError response
@@ -42,7 +72,8 @@ func NewRequest(ctx *fasthttp.RequestCtx) *Request {
 	req.routeParams = make(map[string]string)
 	req.queryParams = make(map[string]string)
 	req.BaseUrl = string(ctx.URI().Path())
-	req.Writer = ctx.Response.BodyWriter()
+	req.Writer = req
+	req.Ctx = context.Background()
 	req.buildQueryParams()
 
 	return req
@@ -57,6 +88,57 @@ func (r *Request) RouteParam(key string) string {
 	return ""
 }
 
+// RoutePattern returns the pattern of the matched route such as /users/:id
+// or "" if no route matched
+func (r *Request) RoutePattern() string {
+	if r.route == nil {
+		return ""
+	}
+
+	return r.route.route
+}
+
+// Principal returns the identity set by an authentication middleware or nil
+func (r *Request) Principal() *Principal {
+	return r.principal
+}
+
+// SetPrincipal stores the authenticated identity for later handlers
+func (r *Request) SetPrincipal(p *Principal) {
+	r.principal = p
+}
+
+// Set stores a value on the request for later middleware and handlers
+func (r *Request) Set(key string, val interface{}) {
+	if r.locals == nil {
+		r.locals = make(map[string]interface{})
+	}
+
+	r.locals[key] = val
+}
+
+// Get returns the value stored under key and whether it was set
+func (r *Request) Get(key string) (interface{}, bool) {
+	val, ok := r.locals[key]
+	return val, ok
+}
+
+// MustGet returns the value stored under key and panics if it isn't set
+func (r *Request) MustGet(key string) interface{} {
+	val, ok := r.locals[key]
+	if !ok {
+		panic(fmt.Sprintf("supernova: key %q is not set on request", key))
+	}
+
+	return val
+}
+
+// Local returns the value stored under key if it is set and of type T
+func Local[T any](r *Request, key string) (T, bool) {
+	val, ok := r.locals[key].(T)
+	return val, ok
+}
+
 // QueryParam checks for and returns param or "" if doesn't exist
 func (r *Request) QueryParam(key string) string {
 	if val, ok := r.queryParams[key]; ok {
@@ -66,14 +148,16 @@ func (r *Request) QueryParam(key string) string {
 	return ""
 }
 
-// Error allows an easy method to set the RESTful standard error response
+// Error allows an easy method to set the RESTful standard error response.
+// Any body already written is discarded but headers set by middleware are kept.
 func (r *Request) Error(statusCode int, msg string, errors ...interface{}) (int, error) {
-	r.Response.Reset()
+	r.Response.ResetBody()
 	newErr := JSONErrors{
 		Error: JSONError{
-			Errors:  errors,
-			Code:    statusCode,
-			Message: msg,
+			Errors:    errors,
+			Code:      statusCode,
+			Message:   msg,
+			RequestID: r.requestID,
 		},
 	}
 	return r.JSON(statusCode, newErr)
@@ -119,6 +203,15 @@ func (r *Request) Send(data interface{}) (int, error) {
 	return 0, errors.New("unsupported type")
 }
 
+// Write appends p to the response body. It fails once the request has timed out.
+func (r *Request) Write(p []byte) (int, error) {
+	if r.TimedOut() {
+		return 0, ErrRequestTimeout
+	}
+
+	return r.RequestCtx.Write(p)
+}
+
 // JSON marshals the given interface object and writes the JSON response.
 func (r *Request) JSON(code int, obj interface{}) (int, error) {
 	jsn, err := json.Marshal(obj)
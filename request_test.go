@@ -83,3 +83,32 @@ func TestRequest_Param(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRequest_Locals(t *testing.T) {
+	r := NewRequest(new(fasthttp.RequestCtx))
+	r.Set("user", "gopher")
+	r.Set("id", 42)
+
+	if v, ok := r.Get("user"); !ok || v != "gopher" {
+		t.Errorf("Expected gopher got %v", v)
+	}
+
+	if _, ok := r.Get("missing"); ok {
+		t.Error("Expected missing key to not be set")
+	}
+
+	if id, ok := Local[int](r, "id"); !ok || id != 42 {
+		t.Errorf("Expected 42 got %d", id)
+	}
+
+	if _, ok := Local[string](r, "id"); ok {
+		t.Error("Expected type mismatch to fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustGet to panic on missing key")
+		}
+	}()
+	r.MustGet("missing")
+}
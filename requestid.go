@@ -0,0 +1,91 @@
+package supernova
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDConfig holds the options for the request ID middleware
+type RequestIDConfig struct {
+	// Header is read for an incoming ID and set on the response, defaults to X-Request-ID
+	Header string
+
+	// Generate returns a new ID when the request doesn't carry one, defaults to a random UUID
+	Generate func() string
+}
+
+// maxRequestIDLen limits the size of IDs accepted from clients
+const maxRequestIDLen = 128
+
+// RequestID returns middleware that gives every request an ID. The ID is
+// taken from the request header, the trace ID of a W3C traceparent header or
+// generated, and is echoed in the response header.
+func RequestID(config RequestIDConfig) func(*Request, func()) {
+	if config.Header == "" {
+		config.Header = "X-Request-ID"
+	}
+
+	if config.Generate == nil {
+		config.Generate = NewUUID
+	}
+
+	return func(req *Request, next func()) {
+		id := string(req.Request.Header.Peek(config.Header))
+		if !validRequestID(id) {
+			id = ""
+			if sc, ok := parseTraceParent(string(req.Request.Header.Peek("traceparent"))); ok {
+				id = sc.TraceID.String()
+			}
+		}
+
+		if id == "" {
+			id = config.Generate()
+		}
+
+		req.requestID = id
+		req.Response.Header.Set(config.Header, id)
+		next()
+	}
+}
+
+// RequestID returns the ID set by the request ID middleware or ""
+func (r *Request) RequestID() string {
+	return r.requestID
+}
+
+// NewUUID returns a random version 4 UUID
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+
+	return string(buf)
+}
+
+// validRequestID reports if a client supplied ID is short and printable so
+// it can't break log lines or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
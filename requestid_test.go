@@ -0,0 +1,60 @@
+package supernova
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	cases := []struct {
+		Name    string
+		Headers string
+		Expect  string
+	}{
+		{"incoming", "X-Request-ID: abc-123\r\n", "abc-123"},
+		{"traceparent", "traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\r\n", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"invalid", "X-Request-ID: bad id\r\n", ""},
+		{"generated", "", ""},
+	}
+
+	for _, c := range cases {
+		var seen string
+
+		s := New()
+		s.Use(RequestID(RequestIDConfig{}))
+		s.Get("/test", func(r *Request) {
+			seen = r.RequestID()
+			r.Error(400, "Bad Request")
+		})
+
+		resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: localhost\r\n"+c.Headers+"\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header := string(resp.Header.Peek("X-Request-ID"))
+		if header != seen {
+			t.Errorf("%s: Expected header %s got %s", c.Name, seen, header)
+		}
+
+		if c.Expect != "" && seen != c.Expect {
+			t.Errorf("%s: Expected %s got %s", c.Name, c.Expect, seen)
+		}
+
+		if c.Expect == "" && !uuid.MatchString(seen) {
+			t.Errorf("%s: Expected generated UUID got %s", c.Name, seen)
+		}
+
+		var body JSONErrors
+		if err := json.Unmarshal(resp.Body(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Error.RequestID != seen {
+			t.Errorf("%s: Expected error body ID %s got %s", c.Name, seen, body.Error.RequestID)
+		}
+	}
+}
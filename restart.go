@@ -0,0 +1,112 @@
+package supernova
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variables used to hand listeners to a restarted process
+const (
+	envListenFDs = "SUPERNOVA_LISTEN_FDS"
+	envReadyFD   = "SUPERNOVA_READY_FD"
+)
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   []net.Listener
+
+	readyOnce sync.Once
+)
+
+// inheritListeners reads the listeners passed by a restarting parent process
+func inheritListeners() {
+	count, err := strconv.Atoi(os.Getenv(envListenFDs))
+	os.Unsetenv(envListenFDs)
+	if err != nil || count <= 0 {
+		return
+	}
+
+	listeners, err := listenersFromFDs(listenFDsStart, count, nil)
+	if err != nil {
+		defaultLogger().Error("Error inheriting listeners", "err", err)
+		return
+	}
+
+	inherited = listeners
+}
+
+// takeInherited returns an inherited listener for the address if the parent
+// was serving one, or nil if a new listener should be opened
+func takeInherited(network, addr string) net.Listener {
+	inheritOnce.Do(inheritListeners)
+
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+
+	for i, ln := range inherited {
+		if inheritedMatches(ln, network, addr) {
+			inherited = append(inherited[:i], inherited[i+1:]...)
+			return ln
+		}
+	}
+
+	return nil
+}
+
+// inheritedMatches reports if ln is listening on the requested address. A
+// port of 0 matches any port as the parent was given a random one too and a
+// missing host matches any host.
+func inheritedMatches(ln net.Listener, network, addr string) bool {
+	switch a := ln.Addr().(type) {
+	case *net.UnixAddr:
+		return network == "unix" && a.Name == addr
+	case *net.TCPAddr:
+		if !strings.HasPrefix(network, "tcp") {
+			return false
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return false
+		}
+
+		p, err := net.LookupPort(network, port)
+		if err != nil || (p != 0 && p != a.Port) {
+			return false
+		}
+
+		ip := net.ParseIP(host)
+		return ip == nil || ip.Equal(a.IP) || (ip.IsUnspecified() && a.IP.IsUnspecified())
+	}
+
+	return false
+}
+
+// listen returns an inherited listener for the address or opens a new one
+func (sn *Server) listen(network, addr string) (net.Listener, error) {
+	if ln := takeInherited(network, addr); ln != nil {
+		sn.logger.Info("Using inherited listener", "addr", ln.Addr().String())
+		return ln, nil
+	}
+
+	return net.Listen(network, addr)
+}
+
+// notifyReady tells a restarting parent process that this process is serving
+func notifyReady() {
+	readyOnce.Do(func() {
+		fd, err := strconv.Atoi(os.Getenv(envReadyFD))
+		os.Unsetenv(envReadyFD)
+		if err != nil {
+			return
+		}
+
+		f := os.NewFile(uintptr(fd), "ready")
+		f.Write([]byte{1})
+		f.Close()
+	})
+}
@@ -0,0 +1,124 @@
+//go:build !windows
+
+package supernova
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_Restart(t *testing.T) {
+	if os.Getenv(envListenFDs) != "" {
+		runRestartChild()
+		return
+	}
+
+	restartCommand = func() (string, []string, error) {
+		path, err := os.Executable()
+		return path, []string{"-test.run=^TestServer_Restart$"}, err
+	}
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/who", func(r *Request) {
+		r.Send("parent")
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ListenAndServe("127.0.0.1:0")
+	}()
+	addr := waitForListener(t, s)
+
+	if body := getBody(t, addr, "/who"); body != "parent" {
+		t.Fatalf("Expected parent got %q", body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.Restart(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected parent to stop serving cleanly got %v", err)
+	}
+
+	if body := getBody(t, addr, "/who"); body != "child" {
+		t.Errorf("Expected child to serve the same address got %q", body)
+	}
+
+	getBody(t, addr, "/quit")
+}
+
+// runRestartChild serves on the inherited listener until /quit is requested
+func runRestartChild() {
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/who", func(r *Request) {
+		r.Send("child")
+	})
+	s.Get("/quit", func(r *Request) {
+		r.Send("bye")
+		go s.Shutdown(context.Background())
+	})
+
+	s.ListenAndServe("127.0.0.1:0")
+}
+
+// getBody requests path from addr on a new connection and returns the body
+func getBody(t *testing.T, addr, path string) string {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n"))
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(resp.Body())
+}
+
+func TestTakeInherited(t *testing.T) {
+	inheritOnce.Do(func() {})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	inherited = []net.Listener{ln}
+	defer func() {
+		inherited = nil
+	}()
+
+	if l := takeInherited("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port+1))); l != nil {
+		t.Error("Expected listener on another port not to be inherited")
+	}
+
+	if l := takeInherited("unix", "/tmp/test.sock"); l != nil {
+		t.Error("Expected tcp listener not to be inherited for a unix socket")
+	}
+
+	if l := takeInherited("tcp", ":"+strconv.Itoa(port)); l != ln {
+		t.Error("Expected listener on the same port to be inherited")
+	}
+
+	if len(inherited) != 0 {
+		t.Errorf("Expected inherited listener to be taken got %d left", len(inherited))
+	}
+}
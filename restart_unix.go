@@ -0,0 +1,143 @@
+//go:build !windows
+
+package supernova
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// restartCommand returns the program and arguments started by Restart
+var restartCommand = func() (string, []string, error) {
+	path, err := os.Executable()
+	return path, os.Args[1:], err
+}
+
+// Restart starts a new copy of the running binary and hands it the listening
+// sockets in the order they started serving. Once the new process reports
+// it's serving, this server is drained with Shutdown(ctx). If the new process
+// doesn't become ready before ctx is done it is killed and this server keeps
+// serving.
+func (sn *Server) Restart(ctx context.Context) error {
+	listeners := sn.servedListeners()
+	if len(listeners) == 0 {
+		return errors.New("server isn't listening")
+	}
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, ln := range listeners {
+		filer, ok := ln.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %T can't be passed to a new process", ln.ln)
+		}
+
+		f, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	path, args, err := restartCommand()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envListenFDs+"="+strconv.Itoa(len(listeners)),
+		envReadyFD+"="+strconv.Itoa(listenFDsStart+len(listeners)),
+	)
+
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return err
+	}
+
+	// Release resets Pid so it's kept for logging
+	pid := cmd.Process.Pid
+	sn.logger.Info("Started new process, waiting for it to be ready", "pid", pid)
+
+	readyErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := ready.Read(buf)
+		readyErr <- err
+	}()
+
+	select {
+	case err = <-readyErr:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("new process didn't become ready: %w", err)
+	}
+	cmd.Process.Release()
+
+	// socket files now belong to the new process
+	for _, ln := range listeners {
+		if ul, ok := ln.ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	sn.logger.Info("New process is ready", "pid", pid)
+	return sn.Shutdown(ctx)
+}
+
+// RestartOnSignal calls Restart when one of the signals is received, allowing
+// timeout for the new process to start and connections to drain. SIGUSR2 is
+// used if no signals are given.
+func (sn *Server) RestartOnSignal(timeout time.Duration, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGUSR2}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		for sig := range ch {
+			sn.logger.Info("Received signal, restarting", "signal", sig.String())
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := sn.Restart(ctx)
+			cancel()
+
+			if err == nil {
+				signal.Stop(ch)
+				return
+			}
+			sn.logger.Error("Error restarting", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,18 @@
+package supernova
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// Restart isn't supported on windows
+func (sn *Server) Restart(ctx context.Context) error {
+	return errors.New("restart isn't supported on windows")
+}
+
+// RestartOnSignal isn't supported on windows
+func (sn *Server) RestartOnSignal(timeout time.Duration, sigs ...os.Signal) {
+	sn.logger.Warn("Restart isn't supported on windows")
+}
@@ -1,10 +1,21 @@
 package supernova
 
+import "time"
+
 // Route is the construct of a single route pattern
 type Route struct {
 	routeFunc        func(*Request)
 	routeParamsIndex map[int]string
 	route            string
+
+	// timeout overrides the server timeout when set
+	timeout time.Duration
+}
+
+// Timeout limits how long the route function may run, overriding the server timeout
+func (r *Route) Timeout(timeout time.Duration) *Route {
+	r.timeout = timeout
+	return r
 }
 
 // call builds the route params & executes the function tied to the route
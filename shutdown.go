@@ -0,0 +1,101 @@
+package supernova
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Shutdown stops accepting connections, waits for in-flight requests to finish
+// until ctx is done, cancels the server context and runs the OnShutdown hooks in order.
+// Calling Shutdown again waits for the first call to finish.
+func (sn *Server) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&sn.shuttingDown, 0, 1) {
+		select {
+		case <-sn.shutdownDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+	defer close(sn.shutdownDone)
+	close(sn.stopping)
+
+	sn.logger.Info("Shutting down")
+
+	listeners := sn.servedListeners()
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln *GracefulListener) {
+			errs <- ln.Shutdown(ctx)
+		}(ln)
+	}
+
+	var err error
+	for range listeners {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	sn.cancel()
+
+	if len(sn.onShutdown) > 0 {
+		sn.logger.Info("Running shutdown hooks", "hooks", len(sn.onShutdown))
+	}
+
+	for _, hook := range sn.onShutdown {
+		hook()
+	}
+
+	sn.logger.Info("Shutdown complete")
+	return err
+}
+
+// OnShutdown adds a function to be run after Shutdown has drained connections
+func (sn *Server) OnShutdown(hook func()) {
+	sn.onShutdown = append(sn.onShutdown, hook)
+}
+
+// ShutdownOnSignal calls Shutdown when one of the signals is received, allowing
+// timeout for connections to drain. SIGINT and SIGTERM are used if none are given.
+func (sn *Server) ShutdownOnSignal(timeout time.Duration, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		sig := <-ch
+		signal.Stop(ch)
+		sn.logger.Info("Received signal", "signal", sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		err := sn.Shutdown(ctx)
+		if err != nil {
+			sn.logger.Error("Error shutting down", "err", err)
+		}
+	}()
+}
+
+// SetShutDownHandler implements function called when SIGTERM signal is received
+//
+// Deprecated: use OnShutdown and ShutdownOnSignal
+func (sn *Server) SetShutDownHandler(shutdownFunc func()) {
+	if shutdownFunc != nil {
+		sn.OnShutdown(shutdownFunc)
+	}
+
+	sn.ShutdownOnSignal(sn.drainTimeout)
+}
+
+// isShuttingDown reports if Shutdown has been called
+func (sn *Server) isShuttingDown() bool {
+	return atomic.LoadInt32(&sn.shuttingDown) == 1
+}
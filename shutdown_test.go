@@ -0,0 +1,162 @@
+package supernova
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	started := make(chan struct{})
+	var order []int
+
+	s := New()
+	s.Get("/slow", func(r *Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		r.Send("done")
+	})
+	s.OnShutdown(func() { order = append(order, 1) })
+	s.OnShutdown(func() { order = append(order, 2) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: test\r\n\r\n"))
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		shutdownErr <- s.Shutdown(ctx)
+	}()
+
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "done" {
+		t.Errorf("Expected in-flight request to finish got %q", resp.Body())
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Error(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected Serve to return nil got %v", err)
+	}
+
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("Expected hooks to run in order got %v", order)
+	}
+
+	if s.ctx.Err() == nil {
+		t.Error("Expected server context to be cancelled")
+	}
+}
+
+func TestServer_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	s := New()
+	s.Get("/stuck", func(r *Request) {
+		close(started)
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /stuck HTTP/1.1\r\nHost: test\r\n\r\n"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err == nil {
+		t.Error("Expected shutdown to report the deadline")
+	}
+	close(release)
+}
+
+func TestServer_ShutdownClosesIdleConns(t *testing.T) {
+	var logs bytes.Buffer
+
+	s := New()
+	s.SetLogHandler(slog.NewTextHandler(&logs, nil))
+	s.SetDrainTimeout(time.Second)
+	s.Get("/test", func(r *Request) {
+		r.Send("ok")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// complete one request and leave the connection open for keep-alive
+	conn.Write([]byte("GET /test HTTP/1.1\r\nHost: test\r\n\r\n"))
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.ActiveConnections(); n != 1 {
+		t.Errorf("Expected 1 active connection got %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.ActiveConnections(); n != 0 {
+		t.Errorf("Expected 0 active connections got %d", n)
+	}
+
+	if !strings.Contains(logs.String(), `msg="Closed idle connections" idle=1`) {
+		t.Errorf("Expected shutdown progress to be logged got %q", logs.String())
+	}
+}
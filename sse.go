@@ -0,0 +1,161 @@
+package supernova
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseKeepAliveInterval is how often a comment is sent on idle event streams
+var sseKeepAliveInterval = 15 * time.Second
+
+// SSEEvent is a single server-sent event
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+
+	// Retry tells the client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+// SSEStream sends events to a client
+type SSEStream struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lastEventID string
+
+	mutex sync.Mutex
+	w     *bufio.Writer
+}
+
+// SSE responds with an event stream and calls fn to send events. Each event
+// is flushed as it's sent and a comment is sent periodically to keep the
+// connection open. The stream's context is done once the client disconnects
+// or the server shuts down. The response is sent after the route returns.
+func (r *Request) SSE(fn func(stream *SSEStream)) {
+	header := &r.Response.Header
+	header.SetContentType("text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+
+	lastEventID := string(r.Request.Header.Peek("Last-Event-ID"))
+	conn := r.Conn()
+
+	parent, stopping := context.Background(), (<-chan struct{})(nil)
+	if r.server != nil {
+		parent, stopping = r.server.ctx, r.server.stopping
+	}
+
+	r.SetBodyStreamWriter(func(w *bufio.Writer) {
+		reqCtx := newRequestContext(parent, conn)
+		defer reqCtx.cancel()
+
+		stream := &SSEStream{
+			ctx:         reqCtx,
+			cancel:      reqCtx.cancel,
+			lastEventID: lastEventID,
+			w:           w,
+		}
+
+		// flush the headers so the client knows the stream is open
+		if w.Flush() != nil {
+			return
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		go stream.keepAlive(stopping, done)
+
+		fn(stream)
+	})
+}
+
+// keepAlive sends comments until done and ends the stream when stopping is closed
+func (s *SSEStream) keepAlive(stopping <-chan struct{}, done chan struct{}) {
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	ctxDone := s.ctx.Done()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctxDone:
+			return
+		case <-stopping:
+			s.cancel()
+			return
+		case <-ticker.C:
+			s.write(": keep-alive\n\n")
+		}
+	}
+}
+
+// Context returns a context done once the client disconnects or the server shuts down
+func (s *SSEStream) Context() context.Context {
+	return s.ctx
+}
+
+// Done is closed once the client disconnects or the server shuts down
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting client
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Send writes the event and flushes it to the client
+func (s *SSEStream) Send(event SSEEvent) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + sseField(event.ID) + "\n")
+	}
+
+	if event.Event != "" {
+		b.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data := strings.ReplaceAll(event.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// write sends raw stream data, ending the stream if the client is gone
+func (s *SSEStream) write(data string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.w.WriteString(data)
+	if err == nil {
+		err = s.w.Flush()
+	}
+
+	if err != nil {
+		s.cancel()
+	}
+
+	return err
+}
+
+// sseField strips line breaks which would end a field early
+func sseField(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
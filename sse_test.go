@@ -0,0 +1,89 @@
+package supernova
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequest_SSE(t *testing.T) {
+	interval := sseKeepAliveInterval
+	sseKeepAliveInterval = 20 * time.Millisecond
+	defer func() {
+		sseKeepAliveInterval = interval
+	}()
+
+	ended := make(chan struct{})
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/events", func(r *Request) {
+		r.SSE(func(stream *SSEStream) {
+			defer close(ended)
+
+			stream.Send(SSEEvent{ID: "6", Event: "update", Data: "resumed after " + stream.LastEventID()})
+			stream.Send(SSEEvent{Data: "line one\nline two", Retry: time.Second})
+			<-stream.Done()
+		})
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /events HTTP/1.1\r\nHost: test\r\nLast-Event-ID: 5\r\n\r\n"))
+	br := bufio.NewReader(conn)
+
+	var got strings.Builder
+	expected := []string{
+		"Content-Type: text/event-stream",
+		"id: 6\nevent: update\ndata: resumed after 5\n\n",
+		"retry: 1000\ndata: line one\ndata: line two\n\n",
+		": keep-alive\n\n",
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected events got %q: %v", got.String(), err)
+		}
+		got.WriteString(strings.TrimSuffix(line, "\r\n") + strings.Repeat("\n", strings.Count(line, "\r\n")))
+
+		all := true
+		for _, e := range expected {
+			if !strings.Contains(got.String(), e) {
+				all = false
+			}
+		}
+
+		if all {
+			break
+		}
+	}
+
+	// shutdown ends the stream instead of waiting for it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Error("Expected stream to end on shutdown")
+	}
+}
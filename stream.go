@@ -0,0 +1,63 @@
+package supernova
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// Stream sends the response with chunked transfer encoding, calling fn to
+// write the body once the route has returned and the response is being sent.
+// Data is sent as fn flushes w so the body is never held in memory. fn isn't
+// called if the body is never sent, for example after a timeout. An error from
+// fn is logged and the connection is closed without ending the body so
+// clients can tell it's incomplete, data not yet flushed is dropped.
+func (r *Request) Stream(contentType string, fn func(w *bufio.Writer) error) {
+	r.Response.Header.SetContentType(contentType)
+	server := r.server
+
+	pr, pw := io.Pipe()
+	r.SetBodyStream(&streamReader{pr: pr, start: func() {
+		w := bufio.NewWriter(pw)
+		err := fn(w)
+		if err != nil && server != nil {
+			server.logger.Error("Error streaming response", "request_id", r.requestID, "err", err)
+		}
+
+		if err == nil {
+			err = w.Flush()
+		}
+
+		// fasthttp only writes the terminating chunk when the body ends cleanly
+		pw.CloseWithError(err)
+	}}, -1)
+}
+
+// streamReader starts writing the body on the first read so the writer never
+// runs alongside the route or blocks forever on a body that isn't sent
+type streamReader struct {
+	pr    *io.PipeReader
+	start func()
+	once  sync.Once
+}
+
+// Read starts the writer and reads what it has written
+func (s *streamReader) Read(p []byte) (int, error) {
+	s.once.Do(func() {
+		go s.start()
+	})
+
+	return s.pr.Read(p)
+}
+
+// Close stops the writer as nothing more will be read
+func (s *streamReader) Close() error {
+	return s.pr.Close()
+}
+
+// SendReader sends the response body from reader without buffering it. size
+// is the length of the body or -1 if unknown, in which case chunked transfer
+// encoding is used. The reader is closed once sent if it's an io.Closer.
+func (r *Request) SendReader(reader io.Reader, size int) {
+	r.SetBodyStream(reader, size)
+}
@@ -0,0 +1,172 @@
+package supernova
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRequest_Stream(t *testing.T) {
+	var logs bytes.Buffer
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Use(AccessLog(AccessLogConfig{Writer: &logs}))
+	s.Get("/stream", func(r *Request) {
+		r.Stream("text/plain", func(w *bufio.Writer) error {
+			for i := 0; i < 3; i++ {
+				w.WriteString("chunk\n")
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	s.Get("/fail", func(r *Request) {
+		r.Stream("text/plain", func(w *bufio.Writer) error {
+			w.WriteString("partial")
+			w.Flush()
+			w.WriteString("dropped")
+			return errors.New("upstream failed")
+		})
+	})
+
+	addr := serveTest(t, s)
+	resp, err := http.Get("http://" + addr + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Expected chunked transfer encoding got %v", resp.TransferEncoding)
+	}
+
+	if string(body) != "chunk\nchunk\nchunk\n" {
+		t.Errorf("Expected 3 chunks got %q", body)
+	}
+
+	if resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected text/plain got %s", resp.Header.Get("Content-Type"))
+	}
+
+	if !strings.Contains(logs.String(), `"status":200`) {
+		t.Errorf("Expected streamed request to be logged got %s", logs.String())
+	}
+
+	resp, err = http.Get("http://" + addr + "/fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err == nil {
+		t.Error("Expected failed stream to end without the terminating chunk")
+	}
+
+	if string(body) != "partial" {
+		t.Errorf("Expected partial body got %q", body)
+	}
+}
+
+func TestRequest_SendReader(t *testing.T) {
+	reader := &closeRecorder{Reader: strings.NewReader("from reader")}
+
+	s := New()
+	s.Get("/sized", func(r *Request) {
+		r.SendReader(reader, reader.Len())
+	})
+	s.Get("/unsized", func(r *Request) {
+		r.SendReader(strings.NewReader("unknown size"), -1)
+	})
+
+	resp, err := doRequest(s, "GET /sized HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "from reader" || resp.Header.ContentLength() != 11 {
+		t.Errorf("Expected sized body got %q length %d", resp.Body(), resp.Header.ContentLength())
+	}
+
+	if !reader.closed {
+		t.Error("Expected reader to be closed")
+	}
+
+	rw := &readWriter{}
+	rw.r.WriteString("GET /unsized HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err := s.server.ServeConn(rw); err != nil {
+		t.Fatal(err)
+	}
+
+	if raw := rw.w.String(); !strings.Contains(raw, "Transfer-Encoding: chunked") || !strings.Contains(raw, "unknown size") {
+		t.Errorf("Expected chunked body got %q", raw)
+	}
+}
+
+func TestRequest_Stream_Lazy(t *testing.T) {
+	var returned, called int32
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/stream", func(r *Request) {
+		r.Stream("text/plain", func(w *bufio.Writer) error {
+			if atomic.LoadInt32(&returned) == 0 {
+				t.Error("Expected fn to run after the route returned")
+			}
+			_, err := w.WriteString("done")
+			return err
+		})
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt32(&returned, 1)
+	})
+	s.Get("/timeout", func(r *Request) {
+		r.Stream("text/plain", func(w *bufio.Writer) error {
+			atomic.StoreInt32(&called, 1)
+			return nil
+		})
+		<-r.Ctx.Done()
+	}).Timeout(10 * time.Millisecond)
+
+	resp, err := doRequest(s, "GET /stream HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "done" {
+		t.Errorf("Expected done got %q", resp.Body())
+	}
+
+	resp, err = doRequest(s, "GET /timeout HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 503 {
+		t.Errorf("Expected 503 got %d", resp.StatusCode())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&called) != 0 {
+		t.Error("Expected fn not to run for a body that was never sent")
+	}
+}
@@ -2,13 +2,13 @@
 package supernova
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
-	"os"
-	"os/signal"
+	"runtime/debug"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -17,17 +17,57 @@ import (
 // Server represents the router and all associated data
 type Server struct {
 	server *fasthttp.Server
-	ln     net.Listener
+
+	// mutex guards the listeners being served
+	mutex     sync.Mutex
+	listeners []*GracefulListener
+
+	// configured holds the listeners added with AddListener
+	configured []ListenerConfig
+
+	// memory is the in-memory listener returned by MemoryListener
+	memory *MemoryListener
+
+	// ctx lives as long as the server and is the parent of every request context
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	// radix tree for looking up routes
 	paths      map[string]*Node
 	middleWare []Middleware
 
-	// shutdown function called when ctl-c is intercepted
-	shutdownHandler func()
+	// tlsConfig is used as the base config when serving TLS
+	tlsConfig *tls.Config
+
+	// certs holds certificate files that are reloaded on change
+	certs *CertManager
+
+	// drainTimeout is how long listeners wait for connections to close
+	drainTimeout time.Duration
+
+	// logger receives diagnostics such as shutdown progress
+	logger Logger
+
+	// onShutdown hooks run in order once Shutdown has drained connections
+	onShutdown   []func()
+	shuttingDown int32
+	shutdownDone chan struct{}
 
-	// debug defines logging for requests
-	debug bool
+	// stopping is closed when Shutdown starts so long lived streams end
+	stopping chan struct{}
+
+	// debug logs every request when set
+	debug func(*Request, func())
+
+	// wsConfig holds the options for WebSocket routes
+	wsConfig WebSocketConfig
+
+	// health serves the health endpoints once enabled
+	health *Health
+
+	// timeout limits how long a route function may run
+	timeout        time.Duration
+	timeoutHandler func(*Request)
 }
 
 // Node holds a single route with accompanying children routes
@@ -52,117 +92,310 @@ type CachedStatic struct {
 // Middleware holds all middleware functions
 type Middleware struct {
 	middleFunc func(*Request, func())
+
+	// name is used for the middleware's span when tracing
+	name string
 }
 
 // New returns new supernova router
 func New() *Server {
 	s := new(Server)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.shutdownDone = make(chan struct{})
+	s.stopping = make(chan struct{})
+	s.drainTimeout = time.Second * 5
+	s.logger = defaultLogger()
 
 	s.server = &fasthttp.Server{
-		Handler: s.handler,
+		Handler:   s.handler,
+		ConnState: trackConnState,
+		Logger:    fasthttpLogger{s.logger},
 	}
 
 	return s
 }
 
-// EnableDebug toggles output for incoming requests
+// EnableDebug toggles output for incoming requests. Requests are logged to
+// stdout in logfmt, use AccessLog for other formats.
 func (sn *Server) EnableDebug(debug bool) {
 	if debug {
-		sn.debug = true
+		sn.debug = AccessLog(AccessLogConfig{Format: AccessLogLogfmt})
 	}
 }
 
-// ListenAndServe starts the server
+// ListenAndServe starts the server. The address is served over IPv4 and IPv6
+// unless it names an address of either family.
 func (sn *Server) ListenAndServe(addr string) error {
-	listener, err := net.Listen("tcp4", addr)
+	listener, err := sn.listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	sn.ln = NewGracefulListener(listener, time.Second*5)
-	return sn.server.Serve(sn.ln)
+	return sn.Serve(listener)
 }
 
-// ListenAndServeTLS starts server with ssl
-func (sn *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
-	listener, err := net.Listen("tcp4", addr)
+// Serve serves incoming connections from the given listener.
+// The listener is wrapped in a GracefulListener if it isn't one already.
+// After Shutdown is called Serve waits for it to complete and returns nil.
+func (sn *Server) Serve(ln net.Listener) error {
+	gl, ok := ln.(*GracefulListener)
+	if !ok {
+		gl = sn.newGracefulListener(ln)
+	}
+
+	return sn.serve(gl, gl)
+}
+
+// serve tracks gl as one of the server's listeners and serves connections
+// from ln, which is gl itself or a listener wrapping it
+func (sn *Server) serve(gl *GracefulListener, ln net.Listener) error {
+	sn.addListener(gl)
+	defer sn.removeListener(gl)
+
+	if sn.isShuttingDown() {
+		<-sn.shutdownDone
+		gl.ln.Close()
+		return nil
+	}
+	notifyReady()
+
+	err := sn.server.Serve(ln)
+	if sn.isShuttingDown() {
+		<-sn.shutdownDone
+		return nil
+	}
+
 	if err != nil {
-		return err
+		sn.logger.Error("Error serving", "addr", gl.Addr().String(), "err", err)
 	}
 
-	sn.ln = NewGracefulListener(listener, time.Second*5)
-	return fasthttp.ListenAndServeTLS(addr, certFile, keyFile, sn.handler)
+	return err
 }
 
-// Serve serves incoming connections from the given listener.
-func (sn *Server) Serve(ln net.Listener) error {
-	return sn.server.Serve(ln)
+// newGracefulListener wraps ln using the server's drain timeout and logger
+func (sn *Server) newGracefulListener(ln net.Listener) *GracefulListener {
+	gl := NewGracefulListener(ln, sn.drainTimeout).(*GracefulListener)
+	gl.logger = sn.logger
+	return gl
+}
+
+// SetDrainTimeout sets how long Close and signal triggered shutdowns wait for
+// open connections to finish. It must be called before serving.
+func (sn *Server) SetDrainTimeout(timeout time.Duration) {
+	sn.drainTimeout = timeout
+}
+
+// ActiveConnections returns the number of open connections across all listeners
+func (sn *Server) ActiveConnections() uint64 {
+	var count uint64
+	for _, ln := range sn.servedListeners() {
+		count += ln.ActiveConnections()
+	}
+
+	return count
+}
+
+// addListener stores a listener being served unless it's already stored
+func (sn *Server) addListener(ln *GracefulListener) {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	for _, l := range sn.listeners {
+		if l == ln {
+			return
+		}
+	}
+	sn.listeners = append(sn.listeners, ln)
+}
+
+// removeListener forgets a listener once it's no longer being served
+func (sn *Server) removeListener(ln *GracefulListener) {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	for i, l := range sn.listeners {
+		if l == ln {
+			sn.listeners = append(sn.listeners[:i], sn.listeners[i+1:]...)
+			return
+		}
+	}
 }
 
-// Close closes existing listener
+// servedListeners returns the listeners being served in the order they started
+func (sn *Server) servedListeners() []*GracefulListener {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	listeners := make([]*GracefulListener, len(sn.listeners))
+	copy(listeners, sn.listeners)
+	return listeners
+}
+
+// Close closes existing listeners and cancels the contexts of running requests
 func (sn *Server) Close() error {
-	return sn.ln.Close()
+	sn.cancel()
+
+	listeners := sn.servedListeners()
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln *GracefulListener) {
+			errs <- ln.Close()
+		}(ln)
+	}
+
+	var err error
+	for range listeners {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// SetTimeout limits how long route functions may run. Routes can override it with Route.Timeout.
+func (sn *Server) SetTimeout(timeout time.Duration) {
+	sn.timeout = timeout
+}
+
+// SetTimeoutHandler sets the function that writes the response when a route times out.
+// By default a 503 error is returned.
+func (sn *Server) SetTimeoutHandler(handler func(*Request)) {
+	sn.timeoutHandler = handler
 }
 
 // handler is the main entry point into the router
 func (sn *Server) handler(ctx *fasthttp.RequestCtx) {
+	sn.serveRequest(ctx, sn.ctx)
+}
+
+// serveRequest handles the request with a context derived from parent and
+// returns the response sent to the client
+func (sn *Server) serveRequest(ctx *fasthttp.RequestCtx, parent context.Context) (resp *fasthttp.Response) {
+	// let keep-alive clients go once the current request is done
+	defer func() {
+		if sn.isShuttingDown() {
+			ctx.SetConnectionClose()
+		}
+	}()
+
 	request := NewRequest(ctx)
-	var logMethod func()
-	if sn.debug {
-		logMethod = getDebugMethod(request)
+	request.server = sn
+	defer func() {
+		resp = request.sentResponse()
+	}()
+	defer sn.recoverPanic(request)
+
+	reqCtx := newRequestContext(parent, ctx.Conn())
+	defer reqCtx.cancel()
+	request.Ctx = reqCtx
+
+	if sn.debug != nil {
+		sn.debug(request, func() {
+			sn.dispatch(request)
+		})
+		return
 	}
 
-	if logMethod != nil {
-		defer logMethod()
+	sn.dispatch(request)
+	return
+}
+
+// recoverPanic logs a panic raised while handling the request and responds with a 500
+func (sn *Server) recoverPanic(request *Request) {
+	v := recover()
+	if v == nil {
+		return
 	}
 
-	// Run Middleware
-	finished := sn.runMiddleware(request)
-	if !finished {
+	sn.logger.Error("Panic handling request",
+		"request_id", request.RequestID(),
+		"method", request.GetMethod(),
+		"path", request.BaseUrl,
+		"panic", fmt.Sprint(v),
+		"stack", string(debug.Stack()),
+	)
+
+	if !request.TimedOut() {
+		request.Error(fasthttp.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// dispatch runs the middleware of the listener the request arrived on and
+// the server then calls the matched route
+func (sn *Server) dispatch(request *Request) {
+	route := func() {
+		sn.callRoute(request)
+	}
+
+	gc := findGracefulConn(request.Conn())
+	if gc == nil || len(gc.ln.middleWare) == 0 {
+		runMiddleware(request, sn.middleWare, route)
 		return
 	}
 
+	runMiddleware(request, gc.ln.middleWare, func() {
+		runMiddleware(request, sn.middleWare, route)
+	})
+}
+
+// callRoute calls the route matching the request or responds with a 404.
+// Panics are recovered here so middleware wrapping the route sees the 500.
+func (sn *Server) callRoute(request *Request) {
+	defer sn.recoverPanic(request)
+
 	route := sn.climbTree(request.GetMethod(), request.BaseUrl)
-	if route != nil {
-		route.call(request)
+	if route == nil {
+		request.RequestCtx.Error("404 Not Found", fasthttp.StatusNotFound)
+		return
+	}
+	request.route = route
+
+	timeout := sn.timeout
+	if route.timeout > 0 {
+		timeout = route.timeout
+	}
+
+	if timeout > 0 {
+		sn.callWithTimeout(route, request, timeout)
 		return
 	}
 
-	ctx.Error("404 Not Found", fasthttp.StatusNotFound)
+	route.call(request)
 }
 
 // All adds route for all http methods
-func (sn *Server) All(route string, routeFunc func(*Request)) {
-	sn.addRoute("", buildRoute(route, routeFunc))
+func (sn *Server) All(route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute("", buildRoute(route, routeFunc))
 }
 
 // Get adds only GET method to route
-func (sn *Server) Get(route string, routeFunc func(*Request)) {
-	sn.addRoute("GET", buildRoute(route, routeFunc))
+func (sn *Server) Get(route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute("GET", buildRoute(route, routeFunc))
 }
 
 // Post adds only POST method to route
-func (sn *Server) Post(route string, routeFunc func(*Request)) {
-	sn.addRoute("POST", buildRoute(route, routeFunc))
+func (sn *Server) Post(route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute("POST", buildRoute(route, routeFunc))
 }
 
 // Put adds only PUT method to route
-func (sn *Server) Put(route string, routeFunc func(*Request)) {
-	sn.addRoute("PUT", buildRoute(route, routeFunc))
+func (sn *Server) Put(route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute("PUT", buildRoute(route, routeFunc))
 }
 
 // Delete adds only DELETE method to route
-func (sn *Server) Delete(route string, routeFunc func(*Request)) {
-	sn.addRoute("DELETE", buildRoute(route, routeFunc))
+func (sn *Server) Delete(route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute("DELETE", buildRoute(route, routeFunc))
 }
 
 // Restricted adds route that is restricted by method
-func (sn *Server) Restricted(method, route string, routeFunc func(*Request)) {
-	sn.addRoute(method, buildRoute(route, routeFunc))
+func (sn *Server) Restricted(method, route string, routeFunc func(*Request)) *Route {
+	return sn.addRoute(method, buildRoute(route, routeFunc))
 }
 
 // addRoute takes route and method and adds it to route tree
-func (sn *Server) addRoute(method string, route *Route) {
+func (sn *Server) addRoute(method string, route *Route) *Route {
 	routeStr := route.route
 	if routeStr[len(routeStr)-1] == '/' {
 		routeStr = routeStr[:len(routeStr)-1]
@@ -203,6 +436,8 @@ func (sn *Server) addRoute(method string, route *Route) {
 			currentNode = node
 		}
 	}
+
+	return route
 }
 
 // getNode builds a new node to be added to the radix tree
@@ -218,37 +453,48 @@ func getNode(isEdge bool, route *Route) *Node {
 
 // climbTree takes in path and traverses tree to find route
 func (sn *Server) climbTree(method, path string) *Route {
-	// strip slashes
-	if path[len(path)-1] == '/' {
-		path = path[1 : len(path)-1]
-	} else {
-		path = path[1:]
+	parts := splitPath(path)
+
+	if node, ok := sn.paths[method]; ok {
+		if route := node.find(parts); route != nil {
+			return route
+		}
 	}
 
-	parts := strings.Split(path, "/")
-	pathLen := len(parts) - 1
+	// fall back to routes registered for all methods
+	if node, ok := sn.paths[""]; ok && method != "" {
+		return node.find(parts)
+	}
 
-	currentNode, ok := sn.paths[method]
-	if !ok {
-		currentNode, ok = sn.paths[""]
-		if !ok {
-			return nil
-		}
+	return nil
+}
+
+// splitPath strips the leading and trailing slash and splits the path into parts
+func splitPath(path string) []string {
+	if len(path) > 0 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+	if len(path) > 0 && path[0] == '/' {
+		path = path[1:]
 	}
 
-	for index, val := range parts {
-		var node *Node
+	return strings.Split(path, "/")
+}
 
-		node = currentNode.children[val]
+// find traverses the tree below n and returns the route matching parts
+func (n *Node) find(parts []string) *Route {
+	pathLen := len(parts) - 1
+	currentNode := n
+
+	for index, val := range parts {
+		node := currentNode.children[val]
 		if node == nil {
 			node = currentNode.children[""]
 		}
 
 		// path not found return
-		if node == nil && method == "" {
+		if node == nil {
 			return nil
-		} else if node == nil {
-			return sn.climbTree("", path)
 		}
 
 		currentNode = node
@@ -259,10 +505,9 @@ func (sn *Server) climbTree(method, path string) *Route {
 				return node.route
 			}
 
-			if node, ok = currentNode.children[""]; ok {
+			if node, ok := currentNode.children[""]; ok {
 				return node.route
 			}
-
 		}
 	}
 
@@ -286,47 +531,36 @@ func (sn *Server) Use(f func(*Request, func())) {
 	}
 	middle := new(Middleware)
 	middle.middleFunc = f
+	middle.name = funcName(f)
 	sn.middleWare = append(sn.middleWare, *middle)
 }
 
-// Internal method that runs the middleware
-func (sn *Server) runMiddleware(req *Request) bool {
-	stackFinished := true
-	for m := range sn.middleWare {
-		nextCalled := false
-		sn.middleWare[m].middleFunc(req, func() {
-			nextCalled = true
-		})
-
-		if !nextCalled {
-			stackFinished = false
-			break
+// runMiddleware calls the middleware in order, each one running the rest of
+// the stack and finally last when it calls next
+func runMiddleware(req *Request, middleWare []Middleware, last func()) {
+	var run func(i int)
+	run = func(i int) {
+		if i == len(middleWare) {
+			last()
+			return
 		}
-	}
-
-	return stackFinished
-}
 
-// SetShutDownHandler implements function called when SIGTERM signal is received
-func (sn *Server) SetShutDownHandler(shutdownFunc func()) {
-	sn.shutdownHandler = shutdownFunc
-
-	sigs := make(chan os.Signal)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		for {
-			select {
-			case <-sigs:
-				err := sn.ln.Close()
-				if err != nil {
-					fmt.Printf("Error closing conn: %s\n", err.Error())
-				}
+		// trace middleware running after the tracing middleware
+		if parent := req.span; parent != nil {
+			span := parent.StartSpan("middleware " + middleWare[i].name)
+			defer span.Finish()
+		}
 
-				if shutdownFunc != nil {
-					shutdownFunc()
-				}
-				os.Exit(0)
+		called := false
+		middleWare[i].middleFunc(req, func() {
+			// calling next more than once has no effect
+			if called {
+				return
 			}
-		}
-	}()
+			called = true
+			run(i + 1)
+		})
+	}
+
+	run(0)
 }
@@ -1,11 +1,15 @@
 package supernova
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"net"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/valyala/fasthttp"
 )
 
 // Test adding Routes
@@ -130,6 +134,67 @@ func TestServer_Use(t *testing.T) {
 	}
 }
 
+func TestServer_Use_Chain(t *testing.T) {
+	var order []string
+
+	s := New()
+	s.Use(func(r *Request, next func()) {
+		order = append(order, "first")
+		next()
+		order = append(order, "first done")
+	})
+	s.Use(func(r *Request, next func()) {
+		order = append(order, "second")
+		next()
+		next()
+	})
+	s.Get("/test", func(r *Request) {
+		order = append(order, "route")
+	})
+
+	err := sendRequest(s, "GET", "/test")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "first,second,route,first done"
+	if got := strings.Join(order, ","); got != expected {
+		t.Errorf("Expected %s got %s", expected, got)
+	}
+}
+
+// code after next used to run before the route, it now runs once the route
+// has finished so middleware can wrap the request
+func TestServer_Use_AfterNext(t *testing.T) {
+	var status int
+
+	s := New()
+	s.Use(func(r *Request, next func()) {
+		next()
+		status = r.Response.StatusCode()
+		r.Response.Header.Set("X-After", "yes")
+	})
+	s.Get("/test", func(r *Request) {
+		if len(r.Response.Header.Peek("X-After")) != 0 {
+			t.Error("Expected route to run before code after next")
+		}
+		r.Response.SetStatusCode(201)
+	})
+
+	resp, err := doRequest(s, "GET /test HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != 201 {
+		t.Errorf("Expected middleware to see 201 got %d", status)
+	}
+
+	if v := string(resp.Header.Peek("X-After")); v != "yes" {
+		t.Errorf("Expected header set after next got %q", v)
+	}
+}
+
 func TestServer_Restricted(t *testing.T) {
 	urlHit := false
 
@@ -218,7 +283,7 @@ func TestServer_EnableDebug(t *testing.T) {
 	s := New()
 	s.EnableDebug(true)
 
-	if !s.debug {
+	if s.debug == nil {
 		t.Error("Debug mode wasn't set")
 	}
 }
@@ -236,7 +301,7 @@ func TestServer_SetShutDownHandler(t *testing.T) {
 
 	})
 
-	if s.shutdownHandler == nil {
+	if len(s.onShutdown) != 1 {
 		t.Error("Shutdown handler not set")
 	}
 }
@@ -253,6 +318,21 @@ func sendRequest(s *Server, method, url string) error {
 	return nil
 }
 
+// doRequest writes the raw request to the server and parses the response
+func doRequest(s *Server, raw string) (*fasthttp.Response, error) {
+	rw := &readWriter{}
+	rw.r.WriteString(raw)
+
+	err := s.server.ServeConn(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(fasthttp.Response)
+	err = resp.Read(bufio.NewReader(&rw.w))
+	return resp, err
+}
+
 //TODO: Benchmark climbTree
 
 type readWriter struct {
@@ -0,0 +1,198 @@
+// Package supernovatest exercises supernova routes in-process without opening ports
+package supernovatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/MordFustang21/supernova"
+	"github.com/valyala/fasthttp"
+)
+
+// Client sends requests to a Server over an in-memory listener
+type Client struct {
+	ln     *supernova.MemoryListener
+	client *fasthttp.Client
+}
+
+// Option changes a request before it's sent
+type Option func(*fasthttp.Request)
+
+// Response is a parsed response with assertion helpers
+type Response struct {
+	*fasthttp.Response
+}
+
+// NewClient serves s on an in-memory listener until Close is called
+func NewClient(s *supernova.Server) *Client {
+	ln := supernova.NewMemoryListener()
+	go s.Serve(ln)
+
+	return &Client{
+		ln:     ln,
+		client: &fasthttp.Client{Dial: ln.Dialer()},
+	}
+}
+
+// Do sends a request to s and returns the response. Requests are served on
+// the server's in-memory listener so every call shares one listener.
+func Do(s *supernova.Server, method, path string, opts ...Option) (*Response, error) {
+	ln := s.MemoryListener()
+	c := &Client{ln: ln, client: &fasthttp.Client{Dial: ln.Dialer()}}
+	defer c.client.CloseIdleConnections()
+
+	return c.Do(method, path, opts...)
+}
+
+// Do sends a request and returns the response
+func (c *Client) Do(method, path string, opts ...Option) (*Response, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI("http://supernovatest" + path)
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	resp := new(fasthttp.Response)
+	err := c.client.Do(req, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{resp}, nil
+}
+
+// Close stops serving on the in-memory listener
+func (c *Client) Close() error {
+	c.client.CloseIdleConnections()
+	return c.ln.Close()
+}
+
+// WithHeader sets a request header
+func WithHeader(name, value string) Option {
+	return func(req *fasthttp.Request) {
+		req.Header.Set(name, value)
+	}
+}
+
+// WithCookie sets a request cookie
+func WithCookie(name, value string) Option {
+	return func(req *fasthttp.Request) {
+		req.Header.SetCookie(name, value)
+	}
+}
+
+// WithBody sets the request body
+func WithBody(body []byte) Option {
+	return func(req *fasthttp.Request) {
+		req.SetBody(body)
+	}
+}
+
+// WithJSON sets the request body to v encoded as JSON
+func WithJSON(v interface{}) Option {
+	return func(req *fasthttp.Request) {
+		body, err := json.Marshal(v)
+		if err != nil {
+			panic(err)
+		}
+
+		req.Header.SetContentType("application/json")
+		req.SetBody(body)
+	}
+}
+
+// Status returns the status code
+func (r *Response) Status() int {
+	return r.StatusCode()
+}
+
+// Header returns the value of a response header
+func (r *Response) Header(name string) string {
+	return string(r.Response.Header.Peek(name))
+}
+
+// Cookie returns the value of a cookie set by the response or ""
+func (r *Response) Cookie(name string) string {
+	c := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(c)
+
+	c.SetKey(name)
+	if !r.Response.Header.Cookie(c) {
+		return ""
+	}
+
+	return string(c.Value())
+}
+
+// String returns the body as a string
+func (r *Response) String() string {
+	return string(r.Body())
+}
+
+// DecodeJSON decodes the body into v
+func (r *Response) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body(), v)
+}
+
+// AssertStatus fails the test if the status code isn't code
+func (r *Response) AssertStatus(t testing.TB, code int) {
+	t.Helper()
+	if r.Status() != code {
+		t.Errorf("Expected status %d got %d: %s", code, r.Status(), r.Body())
+	}
+}
+
+// AssertHeader fails the test if the header doesn't have value
+func (r *Response) AssertHeader(t testing.TB, name, value string) {
+	t.Helper()
+	if got := r.Header(name); got != value {
+		t.Errorf("Expected header %s %q got %q", name, value, got)
+	}
+}
+
+// AssertCookie fails the test if the response didn't set the cookie to value
+func (r *Response) AssertCookie(t testing.TB, name, value string) {
+	t.Helper()
+	if got := r.Cookie(name); got != value {
+		t.Errorf("Expected cookie %s %q got %q", name, value, got)
+	}
+}
+
+// AssertJSON fails the test if the body isn't JSON equal to expected, which
+// may be a value to encode or a JSON string
+func (r *Response) AssertJSON(t testing.TB, expected interface{}) {
+	t.Helper()
+
+	var want []byte
+	switch v := expected.(type) {
+	case string:
+		want = []byte(v)
+	case []byte:
+		want = v
+	default:
+		var err error
+		want, err = json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got, exp interface{}
+	if err := json.Unmarshal(r.Body(), &got); err != nil {
+		t.Errorf("Expected JSON body got %s: %v", r.Body(), err)
+		return
+	}
+
+	if err := json.Unmarshal(want, &exp); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected JSON %s got %s", bytes.TrimSpace(want), bytes.TrimSpace(r.Body()))
+	}
+}
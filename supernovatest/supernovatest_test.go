@@ -0,0 +1,104 @@
+package supernovatest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MordFustang21/supernova"
+	"github.com/valyala/fasthttp"
+)
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDo(t *testing.T) {
+	s := supernova.New()
+	s.Post("/users/:id", func(r *supernova.Request) {
+		var u user
+		if err := r.ReadJSON(&u); err != nil {
+			r.Error(400, "Bad Request")
+			return
+		}
+		u.ID = r.RouteParam("id")
+
+		r.Response.Header.Set("X-Session", string(r.Request.Header.Cookie("session")))
+		r.Response.Header.SetCookie(newCookie("seen", "yes"))
+		r.JSON(201, u)
+	})
+
+	resp, err := Do(s, "POST", "/users/7",
+		WithJSON(user{Name: "gopher"}),
+		WithCookie("session", "abc"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.AssertStatus(t, 201)
+	resp.AssertHeader(t, "X-Session", "abc")
+	resp.AssertCookie(t, "seen", "yes")
+	resp.AssertJSON(t, `{"id":"7","name":"gopher"}`)
+	resp.AssertJSON(t, user{ID: "7", Name: "gopher"})
+
+	resp, err = Do(s, "POST", "/users/7", WithBody([]byte("not json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.AssertStatus(t, 400)
+
+	resp, err = Do(s, "GET", "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.AssertStatus(t, 404)
+}
+
+func TestDo_SharedListener(t *testing.T) {
+	s := supernova.New()
+	metrics := s.EnableMetrics()
+	s.Get("/metrics", metrics.Handler)
+
+	var resp *Response
+	for i := 0; i < 3; i++ {
+		var err error
+		resp, err = Do(s, "GET", "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := strings.Count(resp.String(), `listener="memory"`); n != 1 {
+		t.Errorf("Expected one in-memory listener got %d in\n%s", n, resp.String())
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	s := supernova.New()
+	s.Get("/test", func(r *supernova.Request) {
+		r.Send("ok")
+	})
+
+	c := NewClient(s)
+	resp, err := c.Do("GET", "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.String() != "ok" {
+		t.Errorf("Expected ok got %s", resp.String())
+	}
+
+	c.Close()
+	if _, err := c.Do("GET", "/test"); err == nil {
+		t.Error("Expected request after Close to fail")
+	}
+}
+
+func newCookie(name, value string) *fasthttp.Cookie {
+	c := new(fasthttp.Cookie)
+	c.SetKey(name)
+	c.SetValue(value)
+	return c
+}
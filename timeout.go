@@ -0,0 +1,86 @@
+package supernova
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrRequestTimeout is returned when writing to a request that has timed out
+var ErrRequestTimeout = errors.New("request timed out")
+
+// callWithTimeout runs the route in its own goroutine and answers with the
+// timeout handler if it doesn't finish in time
+func (sn *Server) callWithTimeout(route *Route, req *Request, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(req.Ctx, timeout)
+	defer cancel()
+	req.Ctx = ctx
+
+	// the timeout handler works on a copy so it never races the route. Headers
+	// middleware already set such as CORS are kept on the timeout response.
+	timeoutCtx := new(fasthttp.RequestCtx)
+	req.Request.CopyTo(&timeoutCtx.Request)
+	req.Response.Header.CopyTo(&timeoutCtx.Response.Header)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer sn.recoverPanic(req)
+		route.call(req)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	// the request was cancelled by Close or a disconnect rather than timing
+	// out so the route still gets the rest of its time to finish
+	if ctx.Err() != context.DeadlineExceeded {
+		deadline, _ := ctx.Deadline()
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+	}
+
+	atomic.StoreInt32(&req.timedOut, 1)
+
+	timeoutReq := NewRequest(timeoutCtx)
+	timeoutReq.Ctx = ctx
+	timeoutReq.principal = req.principal
+	timeoutReq.requestID = req.requestID
+	timeoutReq.span = req.span
+	if sn.timeoutHandler != nil {
+		sn.timeoutHandler(timeoutReq)
+	} else {
+		timeoutReq.Error(fasthttp.StatusServiceUnavailable, "Service Unavailable")
+	}
+
+	// the route still holds the request so fasthttp must not reuse it
+	req.timeoutResponse = &timeoutCtx.Response
+	req.TimeoutErrorWithResponse(&timeoutCtx.Response)
+}
+
+// TimedOut reports if the route ran past its timeout and the response was already sent
+func (r *Request) TimedOut() bool {
+	return atomic.LoadInt32(&r.timedOut) == 1
+}
+
+// sentResponse returns the response sent to the client, which is the timeout
+// response once the route has timed out
+func (r *Request) sentResponse() *fasthttp.Response {
+	if r.timeoutResponse != nil {
+		return r.timeoutResponse
+	}
+
+	return &r.Response
+}
@@ -0,0 +1,115 @@
+package supernova
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_SetTimeout(t *testing.T) {
+	writeErr := make(chan error, 1)
+	ctxErr := make(chan error, 1)
+
+	s := New()
+	s.SetTimeout(20 * time.Millisecond)
+	s.Get("/slow", func(r *Request) {
+		<-r.Ctx.Done()
+		ctxErr <- r.Ctx.Err()
+		time.Sleep(10 * time.Millisecond)
+		_, err := r.Send("late")
+		writeErr <- err
+	})
+	s.Get("/fast", func(r *Request) {
+		r.Send("ok")
+	})
+
+	resp, err := doRequest(s, "GET /slow HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 503 {
+		t.Errorf("Expected 503 got %d", resp.StatusCode())
+	}
+
+	if err := <-ctxErr; err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded got %v", err)
+	}
+
+	if err := <-writeErr; err != ErrRequestTimeout {
+		t.Errorf("Expected write after timeout to fail got %v", err)
+	}
+
+	resp, err = doRequest(s, "GET /fast HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 200 || string(resp.Body()) != "ok" {
+		t.Errorf("Expected fast route to finish got %d %q", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestRoute_Timeout(t *testing.T) {
+	s := New()
+	s.SetTimeout(time.Second)
+	s.SetTimeoutHandler(func(r *Request) {
+		r.Error(504, "Gateway Timeout")
+	})
+	s.Use(func(r *Request, next func()) {
+		r.Response.Header.Set("Access-Control-Allow-Origin", "*")
+		next()
+	})
+
+	s.Get("/slow", func(r *Request) {
+		<-r.Ctx.Done()
+	}).Timeout(10 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := doRequest(s, "GET /slow HTTP/1.1\r\nHost: test\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != 504 {
+		t.Errorf("Expected 504 got %d", resp.StatusCode())
+	}
+
+	if v := string(resp.Header.Peek("Access-Control-Allow-Origin")); v != "*" {
+		t.Errorf("Expected middleware header on the timeout response got %q", v)
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Route timeout didn't override server timeout")
+	}
+}
+
+func TestRoute_TimeoutCancelled(t *testing.T) {
+	s := New()
+	s.Get("/slow", func(r *Request) {
+		<-r.Ctx.Done()
+		r.Send(r.Ctx.Err().Error())
+	}).Timeout(time.Second)
+
+	parent, cancel := context.WithCancel(context.Background())
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/slow")
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	s.serveRequest(ctx, parent)
+
+	if ctx.Response.StatusCode() != 200 {
+		t.Errorf("Expected 200 got %d", ctx.Response.StatusCode())
+	}
+
+	if string(ctx.Response.Body()) != context.Canceled.Error() {
+		t.Errorf("Expected %q got %q", context.Canceled.Error(), ctx.Response.Body())
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Cancelled route waited for the timeout")
+	}
+}
@@ -0,0 +1,119 @@
+package supernova
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// SetTLSConfig sets the config used by ListenAndServeTLS and ServeTLS. It can
+// set the minimum version, cipher suites or require client certificates.
+func (sn *Server) SetTLSConfig(config *tls.Config) {
+	sn.tlsConfig = config
+}
+
+// AddCertificate adds a certificate pair served to clients requesting one of
+// its hostnames through SNI. Certificates are reloaded when the files change
+// or the process receives SIGHUP.
+func (sn *Server) AddCertificate(certFile, keyFile string) error {
+	return sn.certManager().Add(certFile, keyFile)
+}
+
+// certManager returns the server's CertManager creating it if needed
+func (sn *Server) certManager() *CertManager {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+
+	if sn.certs == nil {
+		sn.certs = NewCertManager()
+		sn.certs.logger = sn.logger
+	}
+
+	return sn.certs
+}
+
+// ListenAndServeTLS starts server with ssl. certFile and keyFile may be empty
+// if the TLS config or AddCertificate already provide certificates.
+func (sn *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	listener, err := sn.listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return sn.ServeTLS(listener, certFile, keyFile)
+}
+
+// ServeTLS serves TLS connections from the given listener. The listener is
+// wrapped in a GracefulListener so TLS connections are drained on shutdown.
+func (sn *Server) ServeTLS(ln net.Listener, certFile, keyFile string) error {
+	config, err := sn.buildTLSConfig(certFile, keyFile)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	gl, ok := ln.(*GracefulListener)
+	if !ok {
+		gl = sn.newGracefulListener(ln)
+	}
+
+	return sn.serve(gl, tls.NewListener(gl, config))
+}
+
+// buildTLSConfig copies the server TLS config and serves the certificate pair
+// as the listener's default certificate
+func (sn *Server) buildTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	var config *tls.Config
+	if sn.tlsConfig != nil {
+		config = sn.tlsConfig.Clone()
+	} else {
+		config = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	certs := sn.certManager()
+	if certFile != "" || keyFile != "" {
+		// the pair is only served by this listener so it's the default for
+		// clients without SNI, certificates added to the server still match by name
+		own := NewCertManager()
+		own.logger = sn.logger
+		err := own.Add(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.GetCertificate == nil {
+			config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if cert := own.match(hello); cert != nil {
+					return cert, nil
+				}
+				if cert := certs.match(hello); cert != nil {
+					return cert, nil
+				}
+				return own.GetCertificate(hello)
+			}
+			own.Watch(sn.ctx, certWatchInterval)
+			certs.Watch(sn.ctx, certWatchInterval)
+		}
+	} else if certs.len() > 0 && config.GetCertificate == nil {
+		config.GetCertificate = certs.GetCertificate
+		certs.Watch(sn.ctx, certWatchInterval)
+	}
+
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	return config, nil
+}
+
+// ClientCertificate returns the certificate presented by the client over TLS or nil
+func (r *Request) ClientCertificate() *x509.Certificate {
+	state := r.TLSConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return state.PeerCertificates[0]
+}
@@ -0,0 +1,251 @@
+package supernova
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_ServeTLS_ClientCert(t *testing.T) {
+	ca := newTestCert(t, "test ca", nil, nil)
+	server := newTestCert(t, "localhost", ca, []string{"localhost"})
+	client := newTestCert(t, "gopher", ca, nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	s := New()
+	s.SetTLSConfig(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	s.Get("/whoami", func(r *Request) {
+		if cert := r.ClientCertificate(); cert != nil {
+			r.Send(cert.Subject.CommonName)
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.logger = discardLogger{}
+	go s.ServeTLS(ln, "", "")
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		RootCAs:      pool,
+		ServerName:   "localhost",
+		Certificates: []tls.Certificate{*client},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /whoami HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "gopher" {
+		t.Errorf("Expected client certificate gopher got %q", resp.Body())
+	}
+
+	// without a client certificate the handshake fails
+	conn2, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	if err == nil {
+		conn2.Write([]byte("GET /whoami HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		_, err = conn2.Read(make([]byte, 1))
+		conn2.Close()
+	}
+
+	if err == nil {
+		t.Error("Expected connection without client certificate to fail")
+	}
+}
+
+func TestServer_ListenAndServeTLS_Files(t *testing.T) {
+	dir := t.TempDir()
+	cert := newTestCert(t, "localhost", nil, []string{"localhost"})
+	certFile, keyFile := writeTestCert(t, dir, "server", cert)
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/test", func(r *Request) {
+		r.Send("secure")
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ListenAndServeTLS("127.0.0.1:0", certFile, keyFile)
+	}()
+
+	addr := waitForListener(t, s)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Write([]byte("GET /test HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "secure" {
+		t.Errorf("Expected secure got %q", resp.Body())
+	}
+
+	// the idle keep-alive TLS connection must not block shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected ListenAndServeTLS to return nil got %v", err)
+	}
+	conn.Close()
+}
+
+func TestServer_buildTLSConfig_PerListener(t *testing.T) {
+	dir := t.TempDir()
+	aFile, aKey := writeTestCert(t, dir, "a", newTestCert(t, "a", nil, []string{"a.test"}))
+	bFile, bKey := writeTestCert(t, dir, "b", newTestCert(t, "b", nil, []string{"b.test"}))
+
+	s := New()
+	defer s.Close()
+
+	a, err := s.buildTLSConfig(aFile, aKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := s.buildTLSConfig(bFile, bKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clients without SNI get the listener's own certificate
+	for cn, config := range map[string]*tls.Config{"a": a, "b": b} {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := cert.Leaf.Subject.CommonName; got != cn {
+			t.Errorf("Expected listener default %s got %s", cn, got)
+		}
+	}
+}
+
+// discardLogger drops all diagnostics
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...interface{}) {}
+func (discardLogger) Info(string, ...interface{})  {}
+func (discardLogger) Warn(string, ...interface{})  {}
+func (discardLogger) Error(string, ...interface{}) {}
+
+// waitForListener waits until the server is listening and returns its address
+func waitForListener(t *testing.T, s *Server) string {
+	for i := 0; i < 100; i++ {
+		if listeners := s.servedListeners(); len(listeners) > 0 {
+			return listeners[0].Addr().String()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("Server didn't start listening")
+	return ""
+}
+
+// newTestCert creates a certificate signed by parent or self-signed when parent is nil
+func newTestCert(t *testing.T, cn string, parent *tls.Certificate, hosts []string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeTestCert writes the certificate and key as PEM files in dir
+func writeTestCert(t *testing.T, dir, name string, cert *tls.Certificate) (string, string) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
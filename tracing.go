@@ -0,0 +1,261 @@
+package supernova
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the ID as lowercase hex
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String returns the ID as lowercase hex
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated between services
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// Span is a timed operation within a trace
+type Span struct {
+	Name     string
+	Context  SpanContext
+	ParentID SpanID
+	Start    time.Time
+	End      time.Time
+
+	mutex      sync.Mutex
+	attributes map[string]interface{}
+	exporter   SpanExporter
+	ended      bool
+}
+
+// SpanExporter receives spans once they end. Implementations must be safe for concurrent use.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// TracingConfig holds the options for tracing
+type TracingConfig struct {
+	// Exporter receives sampled spans, spans are dropped when nil
+	Exporter SpanExporter
+}
+
+// EnableTracing starts a span for every request named after the matched route
+// with a child span for each middleware added after it. The W3C traceparent
+// and tracestate headers are continued from the request and set on the response.
+func (sn *Server) EnableTracing(config TracingConfig) {
+	sn.Use(func(req *Request, next func()) {
+		parent, ok := parseTraceParent(string(req.Request.Header.Peek("traceparent")))
+
+		span := &Span{
+			Name:       req.GetMethod(),
+			Start:      time.Now(),
+			attributes: make(map[string]interface{}),
+			exporter:   config.Exporter,
+		}
+
+		if ok {
+			span.Context.TraceID = parent.TraceID
+			span.Context.Sampled = parent.Sampled
+			span.Context.TraceState = string(req.Request.Header.Peek("tracestate"))
+			span.ParentID = parent.SpanID
+		} else {
+			rand.Read(span.Context.TraceID[:])
+			span.Context.Sampled = true
+		}
+		rand.Read(span.Context.SpanID[:])
+
+		req.span = span
+		req.Response.Header.Set("traceparent", span.TraceParent())
+		if span.Context.TraceState != "" {
+			req.Response.Header.Set("tracestate", span.Context.TraceState)
+		}
+
+		next()
+
+		span.SetAttribute("http.request.method", req.GetMethod())
+		span.SetAttribute("url.path", req.BaseUrl)
+		span.SetAttribute("http.response.status_code", req.responseStatus())
+		if route := req.RoutePattern(); route != "" {
+			span.Name = req.GetMethod() + " " + route
+			span.SetAttribute("http.route", route)
+		}
+
+		for k, v := range req.routeParams {
+			span.SetAttribute("http.route.param."+k, v)
+		}
+
+		span.Finish()
+	})
+}
+
+// Span returns the span of the request or nil if tracing isn't enabled
+func (r *Request) Span() *Span {
+	return r.span
+}
+
+// StartSpan starts a child of the span with the same trace
+func (s *Span) StartSpan(name string) *Span {
+	child := &Span{
+		Name:       name,
+		ParentID:   s.Context.SpanID,
+		Start:      time.Now(),
+		attributes: make(map[string]interface{}),
+		exporter:   s.exporter,
+	}
+
+	child.Context = s.Context
+	rand.Read(child.Context.SpanID[:])
+	return child
+}
+
+// SetAttribute records a key value pair on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	s.attributes[key] = value
+	s.mutex.Unlock()
+}
+
+// Attributes returns a copy of the span's attributes
+func (s *Span) Attributes() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attrs := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+// Finish records the end time and exports the span if it's sampled.
+// Only the first call has an effect.
+func (s *Span) Finish() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mutex.Unlock()
+
+	if s.exporter != nil && s.Context.Sampled {
+		s.exporter.ExportSpan(s)
+	}
+}
+
+// TraceParent returns the W3C traceparent header value for the span to be
+// sent with outgoing requests
+func (s *Span) TraceParent() string {
+	flags := "00"
+	if s.Context.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + s.Context.TraceID.String() + "-" + s.Context.SpanID.String() + "-" + flags
+}
+
+// parseTraceParent parses a W3C traceparent header value
+func parseTraceParent(v string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+
+	// version 00 has exactly four fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+
+	if sc.TraceID == (TraceID{}) || sc.SpanID == (SpanID{}) {
+		return sc, false
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// funcName returns the name of a function for middleware spans
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "middleware"
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
+
+// InMemoryExporter keeps exported spans in memory for tests
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+// NewInMemoryExporter returns an empty InMemoryExporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return new(InMemoryExporter)
+}
+
+// ExportSpan stores the span
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mutex.Lock()
+	e.spans = append(e.spans, span)
+	e.mutex.Unlock()
+}
+
+// Spans returns the exported spans in the order they ended
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset drops the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mutex.Lock()
+	e.spans = nil
+	e.mutex.Unlock()
+}
@@ -0,0 +1,84 @@
+package supernova
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServer_EnableTracing(t *testing.T) {
+	exporter := NewInMemoryExporter()
+
+	s := New()
+	s.EnableTracing(TracingConfig{Exporter: exporter})
+	s.Use(func(r *Request, next func()) {
+		next()
+	})
+	s.Get("/users/:id", func(r *Request) {
+		r.Send("hello")
+	})
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	resp, err := doRequest(s, "GET /users/5 HTTP/1.1\r\nHost: localhost\r\ntraceparent: "+parent+"\r\ntracestate: vendor=1\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans got %d", len(spans))
+	}
+
+	child, span := spans[0], spans[1]
+	if span.Name != "GET /users/:id" {
+		t.Errorf("Expected span name GET /users/:id got %s", span.Name)
+	}
+
+	if span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected incoming trace ID got %s", span.Context.TraceID)
+	}
+
+	if span.ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected incoming parent ID got %s", span.ParentID)
+	}
+
+	attrs := span.Attributes()
+	if attrs["http.response.status_code"] != 200 || attrs["http.route.param.id"] != "5" || attrs["http.route"] != "/users/:id" {
+		t.Errorf("Expected status, route and params attributes got %v", attrs)
+	}
+
+	if !strings.HasPrefix(child.Name, "middleware ") || child.ParentID != span.Context.SpanID {
+		t.Errorf("Expected middleware child span got %s with parent %s", child.Name, child.ParentID)
+	}
+
+	if got := string(resp.Header.Peek("traceparent")); got != span.TraceParent() {
+		t.Errorf("Expected traceparent %s got %s", span.TraceParent(), got)
+	}
+
+	if got := string(resp.Header.Peek("tracestate")); got != "vendor=1" {
+		t.Errorf("Expected tracestate vendor=1 got %s", got)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	cases := []struct {
+		Value   string
+		Valid   bool
+		Sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-xyz-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+
+	for _, c := range cases {
+		sc, ok := parseTraceParent(c.Value)
+		if ok != c.Valid || sc.Sampled != c.Sampled {
+			t.Errorf("%q: Expected valid %v sampled %v got %v %v", c.Value, c.Valid, c.Sampled, ok, sc.Sampled)
+		}
+	}
+}
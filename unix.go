@@ -0,0 +1,155 @@
+package supernova
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation
+const listenFDsStart = 3
+
+// ListenAndServeUnix serves on a unix domain socket at path with the given file mode.
+// A stale socket left at path by a previous run is removed first, a socket
+// still being served is an error.
+func (sn *Server) ListenAndServeUnix(path string, mode os.FileMode) error {
+	listener, err := sn.listenUnix(path, mode)
+	if err != nil {
+		return err
+	}
+
+	return sn.Serve(listener)
+}
+
+// listenUnix returns an inherited listener for path or listens on a new socket
+func (sn *Server) listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if ln := takeInherited("unix", path); ln != nil {
+		return ln, nil
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+
+		// only remove the socket once nothing is accepting connections on it
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen unix %s: address already in use", path)
+		}
+		if !isConnRefused(err) {
+			return nil, fmt.Errorf("listen unix %s: address in use: %w", path, err)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, mode)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// wsaeconnrefused is the error windows returns when dialing a stale socket
+const wsaeconnrefused = syscall.Errno(10061)
+
+// isConnRefused reports if err is from dialing a socket nothing listens on
+func isConnRefused(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	return errno == syscall.ECONNREFUSED || errno == wsaeconnrefused
+}
+
+// ActivationListeners returns the listeners passed to the process by systemd
+// style socket activation using LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES.
+// The variables are unset so child processes don't inherit them.
+func ActivationListeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+
+	return listenersFromFDs(listenFDsStart, count, names)
+}
+
+// ServeActivated serves every listener passed by socket activation
+func (sn *Server) ServeActivated() error {
+	listeners, err := ActivationListeners()
+	if err != nil {
+		return err
+	}
+
+	if len(listeners) == 0 {
+		return errors.New("no listeners passed by socket activation")
+	}
+
+	gls := make([]*GracefulListener, len(listeners))
+	lns := make([]net.Listener, len(listeners))
+	for i, ln := range listeners {
+		gls[i] = sn.newGracefulListener(ln)
+		lns[i] = gls[i]
+	}
+
+	return sn.serveAll(gls, lns)
+}
+
+// listenersFromFDs builds listeners from count descriptors beginning at start
+func listenersFromFDs(start, count int, names []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		fd := start + i
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// FileListener duplicates the descriptor so the original is closed
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket activation fd %d: %w", fd, err)
+		}
+
+		listeners = append(listeners, ln)
+	}
+
+	return listeners, nil
+}
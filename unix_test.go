@@ -0,0 +1,99 @@
+package supernova
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_ListenAndServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "supernova.sock")
+
+	// a stale socket from a previous run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := New()
+	s.logger = discardLogger{}
+	s.Get("/test", func(r *Request) {
+		r.Send("unix")
+	})
+
+	go s.ListenAndServeUnix(path, 0660)
+	waitForListener(t, s)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	}()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("Expected mode 0660 got %o", info.Mode().Perm())
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /test HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n"))
+	resp := new(fasthttp.Response)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp.Body()) != "unix" {
+		t.Errorf("Expected unix got %q", resp.Body())
+	}
+}
+
+func TestServer_ListenAndServeUnix_InUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.sock")
+
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	s := New()
+	if err := s.ListenAndServeUnix(path, 0660); err == nil {
+		t.Fatal("Expected error listening on a socket in use")
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Expected live socket to be kept got %s", err)
+	}
+	conn.Close()
+}
+
+func TestActivationListeners_OtherPID(t *testing.T) {
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := ActivationListeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Expected variables for another process to be ignored got %v %v", listeners, err)
+	}
+
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("Expected LISTEN_FDS to be unset")
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package supernova
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestListenersFromFDs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners, err := listenersFromFDs(fd, 1, []string{"http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listeners) != 1 {
+		t.Fatalf("Expected 1 listener got %d", len(listeners))
+	}
+	defer listeners[0].Close()
+
+	if listeners[0].Addr().String() != ln.Addr().String() {
+		t.Errorf("Expected %s got %s", ln.Addr(), listeners[0].Addr())
+	}
+}
@@ -0,0 +1,719 @@
+package supernova
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MessageType is the type of a WebSocket data message
+type MessageType int
+
+// WebSocket message types
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// WebSocket close codes defined by RFC 6455
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// frame opcodes
+const (
+	opContinuation = 0
+	opText         = 1
+	opBinary       = 2
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// websocketGUID is appended to the client key to build the accept header
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Timeouts for WebSocket writes and the close handshake
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsCloseTimeout = 5 * time.Second
+)
+
+// ErrWebSocketClosed is returned when writing after the close handshake started
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// CloseError is returned by ReadMessage once the peer closes the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error returns the close code and reason
+func (e *CloseError) Error() string {
+	return "websocket closed: " + strconv.Itoa(e.Code) + " " + e.Reason
+}
+
+// WebSocketConfig holds the options for WebSocket routes
+type WebSocketConfig struct {
+	// Subprotocols are the supported protocols in order of preference
+	Subprotocols []string
+
+	// CheckOrigin reports if the request may be upgraded. By default requests
+	// without an Origin header or whose Origin host matches Host are allowed.
+	CheckOrigin func(*Request) bool
+
+	// EnableCompression negotiates permessage-deflate with clients offering it
+	EnableCompression bool
+
+	// PingInterval is how often pings are sent, defaults to 30 seconds. The
+	// connection is closed if nothing is read for twice the interval while
+	// the handler is reading. A negative value disables pings.
+	PingInterval time.Duration
+
+	// ReadLimit is the maximum size of a message, defaults to 16 MiB
+	ReadLimit int64
+}
+
+// WebSocket is an upgraded connection
+type WebSocket struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+	compress    bool
+	readLimit   int64
+	pongWait    time.Duration
+
+	// request data copied before the connection was hijacked
+	header      fasthttp.RequestHeader
+	routeParams map[string]string
+	principal   *Principal
+	remoteAddr  net.Addr
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	readMutex sync.Mutex
+
+	writeMutex sync.Mutex
+	closeSent  bool
+	flateBuf   bytes.Buffer
+	flateW     *flate.Writer
+
+	closeOnce     sync.Once
+	closeReceived chan struct{}
+}
+
+// SetWebSocketConfig sets the options used by routes added with WebSocket
+func (sn *Server) SetWebSocketConfig(config WebSocketConfig) {
+	sn.wsConfig = config
+}
+
+// WebSocket adds a GET route that upgrades to a WebSocket and runs handler
+// with the connection. The connection is closed when handler returns and
+// open connections are closed with CloseGoingAway when the server shuts down.
+func (sn *Server) WebSocket(path string, handler func(*WebSocket)) *Route {
+	return sn.Get(path, func(req *Request) {
+		sn.upgrade(req, handler)
+	})
+}
+
+// upgrade performs the opening handshake and hijacks the connection
+func (sn *Server) upgrade(req *Request, handler func(*WebSocket)) {
+	config := sn.wsConfig
+	if config.PingInterval == 0 {
+		config.PingInterval = 30 * time.Second
+	}
+	if config.ReadLimit <= 0 {
+		config.ReadLimit = 16 << 20
+	}
+
+	header := &req.Request.Header
+	if !headerContainsToken(string(header.Peek("Connection")), "upgrade") ||
+		!strings.EqualFold(string(header.Peek("Upgrade")), "websocket") {
+		req.Error(fasthttp.StatusBadRequest, "Expected WebSocket upgrade")
+		return
+	}
+
+	if string(header.Peek("Sec-WebSocket-Version")) != "13" {
+		req.Response.Header.Set("Sec-WebSocket-Version", "13")
+		req.Error(fasthttp.StatusUpgradeRequired, "Unsupported WebSocket version")
+		return
+	}
+
+	key := string(header.Peek("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		req.Error(fasthttp.StatusBadRequest, "Invalid Sec-WebSocket-Key")
+		return
+	}
+
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+
+	if !checkOrigin(req) {
+		req.Error(fasthttp.StatusForbidden, "Origin not allowed")
+		return
+	}
+
+	resp := &req.Response.Header
+	resp.Set("Upgrade", "websocket")
+	resp.Set("Connection", "Upgrade")
+	resp.Set("Sec-WebSocket-Accept", websocketAccept(key))
+
+	subprotocol := selectSubprotocol(string(header.Peek("Sec-WebSocket-Protocol")), config.Subprotocols)
+	if subprotocol != "" {
+		resp.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	compress := config.EnableCompression && acceptDeflate(string(header.Peek("Sec-WebSocket-Extensions")))
+	if compress {
+		resp.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+	req.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+
+	ws := &WebSocket{
+		subprotocol:   subprotocol,
+		compress:      compress,
+		readLimit:     config.ReadLimit,
+		routeParams:   req.routeParams,
+		principal:     req.principal,
+		remoteAddr:    req.RemoteAddr(),
+		closeReceived: make(chan struct{}),
+	}
+	header.CopyTo(&ws.header)
+
+	if config.PingInterval > 0 {
+		ws.pongWait = 2 * config.PingInterval
+	}
+
+	req.Hijack(func(c net.Conn) {
+		ws.conn = c
+		ws.br = bufio.NewReader(c)
+		ws.ctx, ws.cancel = context.WithCancel(sn.ctx)
+		defer ws.cancel()
+
+		go ws.watch(sn.stopping, config.PingInterval)
+
+		handler(ws)
+		ws.Close(CloseNormal, "")
+	})
+}
+
+// watch sends pings and closes the connection when the server shuts down
+func (ws *WebSocket) watch(stopping <-chan struct{}, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ws.ctx.Done():
+			return
+		case <-stopping:
+			ws.Close(CloseGoingAway, "server shutting down")
+			return
+		case <-tick:
+			if ws.writeFrame(opPing, nil, false) != nil {
+				return
+			}
+		}
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol or ""
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RouteParam returns the route param of the upgraded request or ""
+func (ws *WebSocket) RouteParam(key string) string {
+	return ws.routeParams[key]
+}
+
+// Header returns a header of the upgraded request
+func (ws *WebSocket) Header(name string) string {
+	return string(ws.header.Peek(name))
+}
+
+// Principal returns the identity set by an authentication middleware or nil
+func (ws *WebSocket) Principal() *Principal {
+	return ws.principal
+}
+
+// RemoteAddr returns the client address
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.remoteAddr
+}
+
+// Context is done once the connection is closed or the server shuts down
+func (ws *WebSocket) Context() context.Context {
+	return ws.ctx
+}
+
+// ReadMessage returns the next data message. Pings are answered while
+// reading and a *CloseError is returned once the peer closes the connection.
+func (ws *WebSocket) ReadMessage() (MessageType, []byte, error) {
+	ws.readMutex.Lock()
+	defer ws.readMutex.Unlock()
+
+	return ws.readMessage()
+}
+
+// readMessage reads frames until a whole data message or a close frame arrives
+func (ws *WebSocket) readMessage() (MessageType, []byte, error) {
+	var (
+		msgType    MessageType
+		msg        []byte
+		compressed bool
+		started    bool
+	)
+
+	for {
+		fin, rsv1, opcode, payload, err := ws.readFrame(int64(len(msg)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			ws.writeFrame(opPong, payload, false)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, ws.handleClose(payload)
+		case opText, opBinary:
+			if started {
+				return 0, nil, ws.fail(CloseProtocolError, "expected continuation frame")
+			}
+			if rsv1 && !ws.compress {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected compressed frame")
+			}
+			started = true
+			msgType = MessageType(opcode)
+			compressed = rsv1
+			msg = payload
+		case opContinuation:
+			if !started || rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			msg = append(msg, payload...)
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if !fin {
+			continue
+		}
+
+		if compressed {
+			msg, err = ws.inflate(msg)
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, ws.fail(CloseInvalidPayload, "invalid UTF-8")
+		}
+
+		return msgType, msg, nil
+	}
+}
+
+// readFrame reads a single frame, read is the size of the message so far
+func (ws *WebSocket) readFrame(read int64) (fin, rsv1 bool, opcode byte, payload []byte, err error) {
+	// once closing the close handshake's deadline applies instead
+	if ws.pongWait > 0 && !ws.closing() {
+		ws.conn.SetReadDeadline(time.Now().Add(ws.pongWait))
+	}
+
+	var head [2]byte
+	if _, err = io.ReadFull(ws.br, head[:]); err != nil {
+		return
+	}
+
+	fin = head[0]&0x80 != 0
+	rsv1 = head[0]&0x40 != 0
+	opcode = head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	if head[0]&0x30 != 0 {
+		err = ws.fail(CloseProtocolError, "reserved bits set")
+		return
+	}
+
+	if !masked {
+		err = ws.fail(CloseProtocolError, "client frames must be masked")
+		return
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n > 1<<63-1 {
+			err = ws.fail(CloseProtocolError, "invalid frame length")
+			return
+		}
+		length = int64(n)
+	}
+
+	if opcode >= opClose && (!fin || length > 125 || rsv1) {
+		err = ws.fail(CloseProtocolError, "invalid control frame")
+		return
+	}
+
+	// compare against what's left so huge lengths can't overflow the sum
+	if opcode < opClose && length > ws.readLimit-read {
+		err = ws.fail(CloseMessageTooBig, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// handleClose answers a close frame from the peer and returns the CloseError
+func (ws *WebSocket) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+			ws.fail(CloseProtocolError, "invalid close frame")
+			return closeErr
+		}
+	} else if len(payload) == 1 {
+		ws.fail(CloseProtocolError, "invalid close frame")
+		return closeErr
+	}
+
+	ws.closeOnce.Do(func() {
+		close(ws.closeReceived)
+	})
+
+	// echo the code if we didn't start the close handshake
+	ws.sendClose(closeErr.Code, "")
+	ws.conn.Close()
+	return closeErr
+}
+
+// fail closes the connection after a protocol error and returns the error
+func (ws *WebSocket) fail(code int, reason string) error {
+	ws.sendClose(code, reason)
+	ws.conn.Close()
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends a data message
+func (ws *WebSocket) WriteMessage(msgType MessageType, data []byte) error {
+	if msgType != TextMessage && msgType != BinaryMessage {
+		return errors.New("invalid message type")
+	}
+
+	if !ws.compress {
+		return ws.writeFrame(byte(msgType), data, false)
+	}
+
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	compressed, err := ws.deflate(data)
+	if err != nil {
+		return err
+	}
+
+	return ws.writeFrameLocked(byte(msgType), compressed, true)
+}
+
+// Close starts the close handshake, waits briefly for the peer to answer and
+// closes the connection
+func (ws *WebSocket) Close(code int, reason string) error {
+	if !ws.sendClose(code, reason) {
+		return nil
+	}
+	defer ws.conn.Close()
+
+	// read the answer ourselves unless the handler is already reading
+	if ws.readMutex.TryLock() {
+		defer ws.readMutex.Unlock()
+
+		ws.conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+		for {
+			_, _, err := ws.readMessage()
+			if err != nil {
+				return nil
+			}
+		}
+	}
+
+	select {
+	case <-ws.closeReceived:
+	case <-time.After(wsCloseTimeout):
+	}
+
+	return nil
+}
+
+// closing reports if a close frame has been sent
+func (ws *WebSocket) closing() bool {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	return ws.closeSent
+}
+
+// sendClose writes a close frame once and reports if this call wrote it
+func (ws *WebSocket) sendClose(code int, reason string) bool {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	if ws.closeSent {
+		return false
+	}
+
+	var payload []byte
+	if code != CloseNoStatus {
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		payload = make([]byte, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		copy(payload[2:], reason)
+	}
+
+	ws.writeFrameLocked(opClose, payload, false)
+	ws.closeSent = true
+	if ws.cancel != nil {
+		ws.cancel()
+	}
+
+	return true
+}
+
+// writeFrame writes a single frame
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte, rsv1 bool) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	return ws.writeFrameLocked(opcode, payload, rsv1)
+}
+
+// writeFrameLocked writes a single unmasked frame, the write mutex must be held
+func (ws *WebSocket) writeFrameLocked(opcode byte, payload []byte, rsv1 bool) error {
+	header := make([]byte, 2, 10+len(payload))
+	header[0] = 0x80 | opcode
+	if rsv1 {
+		header[0] |= 0x40
+	}
+
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// deflateTail ends every message compressed with permessage-deflate
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// deflate compresses a message without context takeover, the write mutex must be held
+func (ws *WebSocket) deflate(data []byte) ([]byte, error) {
+	ws.flateBuf.Reset()
+	if ws.flateW == nil {
+		w, err := flate.NewWriter(&ws.flateBuf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		ws.flateW = w
+	} else {
+		ws.flateW.Reset(&ws.flateBuf)
+	}
+
+	if _, err := ws.flateW.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := ws.flateW.Flush(); err != nil {
+		return nil, err
+	}
+
+	out := bytes.TrimSuffix(ws.flateBuf.Bytes(), deflateTail)
+	return append([]byte(nil), out...), nil
+}
+
+// inflate decompresses a message enforcing the read limit
+func (ws *WebSocket) inflate(data []byte) ([]byte, error) {
+	// the final empty block lets the reader end without an unexpected EOF
+	r := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff}),
+	))
+	defer r.Close()
+
+	msg, err := ioutil.ReadAll(io.LimitReader(r, ws.readLimit+1))
+	if err != nil {
+		return nil, ws.fail(CloseInvalidPayload, "invalid compressed data")
+	}
+
+	if int64(len(msg)) > ws.readLimit {
+		return nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+
+	return msg, nil
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept value for the client key
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin allows requests without an Origin or whose Origin host matches Host
+func sameOrigin(req *Request) bool {
+	origin := string(req.Request.Header.Peek("Origin"))
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, string(req.Host()))
+}
+
+// selectSubprotocol returns the first supported protocol the client offered
+func selectSubprotocol(offered string, supported []string) string {
+	for _, s := range supported {
+		if headerContainsToken(offered, s) {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// acceptDeflate reports if the client offered permessage-deflate with
+// parameters the server can honour
+func acceptDeflate(extensions string) bool {
+	for _, ext := range strings.Split(extensions, ",") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+
+		ok := true
+		for _, p := range params[1:] {
+			name := strings.TrimSpace(p)
+			if i := strings.Index(name, "="); i >= 0 {
+				// compress/flate always uses a 32KB window
+				if strings.TrimSpace(name[:i]) == "server_max_window_bits" && strings.Trim(strings.TrimSpace(name[i+1:]), `"`) != "15" {
+					ok = false
+				}
+			}
+		}
+
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerContainsToken reports if the comma separated header contains token
+func headerContainsToken(header, token string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validCloseCode reports if a close code may be sent by a peer
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+
+	return false
+}
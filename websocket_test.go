@@ -0,0 +1,337 @@
+package supernova
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServer_WebSocket(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.SetWebSocketConfig(WebSocketConfig{Subprotocols: []string{"chat"}})
+	s.WebSocket("/echo/:room", func(ws *WebSocket) {
+		ws.WriteMessage(TextMessage, []byte("joined "+ws.RouteParam("room")+" with "+ws.Subprotocol()))
+		for {
+			mt, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			ws.WriteMessage(mt, msg)
+		}
+	})
+
+	addr := serveTest(t, s)
+	c := dialWebSocket(t, addr, "/echo/lobby", "Sec-WebSocket-Protocol: chat, other\r\n")
+	defer c.conn.Close()
+
+	if got := string(c.resp.Header.Peek("Sec-WebSocket-Protocol")); got != "chat" {
+		t.Errorf("Expected subprotocol chat got %q", got)
+	}
+
+	if _, msg := c.read(t); string(msg) != "joined lobby with chat" {
+		t.Errorf("Expected greeting got %q", msg)
+	}
+
+	// a fragmented message with a ping in between
+	c.write(t, false, opText, []byte("hel"), false)
+	c.write(t, true, opPing, []byte("are you there"), false)
+	c.write(t, true, opContinuation, []byte("lo"), false)
+
+	if op, msg := c.read(t); op != opPong || string(msg) != "are you there" {
+		t.Errorf("Expected pong got %d %q", op, msg)
+	}
+
+	if op, msg := c.read(t); op != opText || string(msg) != "hello" {
+		t.Errorf("Expected echo hello got %d %q", op, msg)
+	}
+
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, CloseNormal)
+	c.write(t, true, opClose, payload, false)
+
+	if op, msg := c.read(t); op != opClose || binary.BigEndian.Uint16(msg) != CloseNormal {
+		t.Errorf("Expected close echo got %d %v", op, msg)
+	}
+}
+
+func TestServer_WebSocket_Compression(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.SetWebSocketConfig(WebSocketConfig{EnableCompression: true})
+	s.WebSocket("/echo", func(ws *WebSocket) {
+		mt, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		ws.WriteMessage(mt, bytes.ToUpper(msg))
+	})
+
+	addr := serveTest(t, s)
+	c := dialWebSocket(t, addr, "/echo", "Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits\r\n")
+	defer c.conn.Close()
+
+	if ext := string(c.resp.Header.Peek("Sec-WebSocket-Extensions")); !strings.HasPrefix(ext, "permessage-deflate") {
+		t.Fatalf("Expected permessage-deflate got %q", ext)
+	}
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	fw.Write([]byte(strings.Repeat("compress me ", 20)))
+	fw.Flush()
+	c.write(t, true, opText, bytes.TrimSuffix(buf.Bytes(), deflateTail), true)
+
+	op, rsv1, msg := c.readFrame(t)
+	if op != opText || !rsv1 {
+		t.Fatalf("Expected compressed text frame got %d %v", op, rsv1)
+	}
+
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(msg), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+	plain, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plain) != strings.Repeat("COMPRESS ME ", 20) {
+		t.Errorf("Expected upper cased echo got %q", plain)
+	}
+}
+
+func TestServer_WebSocket_Handshake(t *testing.T) {
+	s := New()
+	s.WebSocket("/ws", func(ws *WebSocket) {})
+
+	cases := []struct {
+		Headers string
+		Code    int
+	}{
+		{"Connection: keep-alive\r\n", 400},
+		{"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 8\r\n", 426},
+		{"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: short\r\n", 400},
+		{"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nOrigin: http://evil.com\r\n", 403},
+	}
+
+	for _, c := range cases {
+		resp, err := doRequest(s, "GET /ws HTTP/1.1\r\nHost: localhost\r\n"+c.Headers+"\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode() != c.Code {
+			t.Errorf("%q: Expected %d got %d", c.Headers, c.Code, resp.StatusCode())
+		}
+	}
+
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected RFC 6455 accept value got %s", got)
+	}
+}
+
+func TestServer_WebSocket_Shutdown(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.WebSocket("/ws", func(ws *WebSocket) {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	addr := serveTest(t, s)
+	c := dialWebSocket(t, addr, "/ws", "")
+	defer c.conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		done <- s.Shutdown(ctx)
+	}()
+
+	op, msg := c.read(t)
+	if op != opClose || binary.BigEndian.Uint16(msg) != CloseGoingAway {
+		t.Fatalf("Expected going away close got %d %v", op, msg)
+	}
+	c.write(t, true, opClose, msg[:2], false)
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected shutdown to complete got %v", err)
+	}
+}
+
+func TestServer_WebSocket_FrameTooBig(t *testing.T) {
+	s := New()
+	s.logger = discardLogger{}
+	s.WebSocket("/ws", func(ws *WebSocket) {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	addr := serveTest(t, s)
+	c := dialWebSocket(t, addr, "/ws", "")
+	defer c.conn.Close()
+
+	// a continuation claiming the largest length must not overflow the limit check
+	c.write(t, false, opText, []byte("a"), false)
+	frame := []byte{0x80 | opContinuation, 0x80 | 127}
+	frame = binary.BigEndian.AppendUint64(frame, 1<<63-1)
+	frame = append(frame, 1, 2, 3, 4)
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	op, msg := c.read(t)
+	if op != opClose || binary.BigEndian.Uint16(msg) != CloseMessageTooBig {
+		t.Errorf("Expected message too big close got %d %v", op, msg)
+	}
+}
+
+func TestWebSocket_CloseDeadline(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	ws := &WebSocket{
+		conn:      conn,
+		br:        bufio.NewReader(conn),
+		readLimit: 1024,
+		pongWait:  time.Minute,
+		closeSent: true,
+	}
+
+	// reading the close answer keeps the close deadline instead of waiting for pongs
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(50 * time.Millisecond))
+	if _, _, _, _, err := ws.readFrame(0); err == nil {
+		t.Fatal("Expected read to time out")
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected close deadline to be kept took %s", d)
+	}
+}
+
+// serveTest serves s on a local port until the test ends
+func serveTest(t *testing.T, s *Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	return ln.Addr().String()
+}
+
+// wsClient is a minimal WebSocket client for tests
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *fasthttp.Response
+}
+
+// dialWebSocket performs the opening handshake
+func dialWebSocket(t *testing.T, addr, path, headers string) *wsClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: " + addr + "\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nOrigin: http://" + addr + "\r\n" + headers + "\r\n"))
+
+	c := &wsClient{conn: conn, br: bufio.NewReader(conn), resp: new(fasthttp.Response)}
+	c.resp.SkipBody = true
+	if err := c.resp.Read(c.br); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.resp.StatusCode() != 101 {
+		t.Fatalf("Expected 101 got %d", c.resp.StatusCode())
+	}
+
+	if got := string(c.resp.Header.Peek("Sec-WebSocket-Accept")); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected accept header got %s", got)
+	}
+
+	return c
+}
+
+// write sends a masked frame
+func (c *wsClient) write(t *testing.T, fin bool, opcode byte, payload []byte, rsv1 bool) {
+	head := []byte{opcode, 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	if rsv1 {
+		head[0] |= 0x40
+	}
+
+	if len(payload) > 125 {
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	} else {
+		head[1] |= byte(len(payload))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+
+	frame := append(append(head, mask...), masked...)
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read returns the next frame's opcode and payload
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	op, _, payload := c.readFrame(t)
+	return op, payload
+}
+
+// readFrame reads an unmasked server frame
+func (c *wsClient) readFrame(t *testing.T) (byte, bool, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	length := int(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	return head[0] & 0x0f, head[0]&0x40 != 0, payload
+}